@@ -58,7 +58,7 @@
 //  c, err := acfmgr.NewCredFileSession("~/.aws/credentials")
 //  check(err)
 //  profileInput := acfmgr.ProfileEntryInput{
-//      Credential: <some sts.Credentials object>,
+//      Credential: <some aws.Credentials object>,
 //      ProfileEntryName: "devaccount1",
 //  }
 //  err = c.NewEntry(profileInput)
@@ -66,6 +66,13 @@
 //  err = c.AssertEntries()
 //  check(err)
 //
+// Services still on aws-sdk-go v1 can convert their credentials with
+// CredentialsFromSTS, CredentialsFromV1 or CredentialsFromV1Value:
+//
+//  creds, err := acfmgr.CredentialsFromSTS(assumeRoleOutput.Credentials)
+//  check(err)
+//  err = c.NewEntry(&acfmgr.ProfileEntryInput{Credential: creds, ProfileEntryName: "devaccount1"})
+//
 // Another Sample using more of the input parameters:
 //  c, err := acfmgr.NewCredFileSession("~/.aws/credentials")
 //  check(err)
@@ -84,15 +91,13 @@
 package acfmgr
 
 import (
-	"bufio"
 	"bytes"
-	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"io/ioutil"
 	"os"
 	"os/user"
-	"regexp"
+	"path/filepath"
 	"strings"
 	"text/template"
 	"time"
@@ -114,7 +119,7 @@ const credFileTemplate = `# DO NOT EDIT
 {{- if .HasRegion}}
 region = {{.Region}}{{end}}
 {{- if .HasOutput}}
-output = {{.Output}}{{end}}
+output = {{.OutputFormat}}{{end}}
 aws_access_key_id = {{.AccessKeyID}}
 aws_secret_access_key = {{.SecretAccessKey}}
 aws_session_token = {{.SessionToken}}
@@ -128,6 +133,20 @@ func init() {
 // NewCredFileSession creates a new interactive credentials file
 // session. Needs target filename and returns CredFile obj and err.
 func NewCredFileSession(filename string) (cf *CredFile, err error) {
+	return newSession(filename, "", false)
+}
+
+// OpenCredFile loads an existing credentials file without creating
+// it or its directory. If the file doesn't exist the error wraps
+// os.ErrNotExist. Use it for anything that only reads profiles.
+func OpenCredFile(filename string) (cf *CredFile, err error) {
+	return newSession(filename, "", true)
+}
+
+// newSession loads filename into a new CredFile. prefix is
+// hidden from section names, see NewConfigFileSession. When
+// noCreate is true a missing file is an error.
+func newSession(filename, prefix string, noCreate bool) (cf *CredFile, err error) {
 	usr, err := user.Current()
 	if err != nil {
 		return cf, err
@@ -139,7 +158,10 @@ func NewCredFileSession(filename string) (cf *CredFile, err error) {
 	}
 	credfile := CredFile{filename: filenameExpanded,
 		currBuff: new(bytes.Buffer),
-		reSep:    regexp.MustCompile(`\[.*\]`),
+		lockOpts: DefaultLockOptions,
+		names:    &ProfileNamePolicy{},
+		prefix:   prefix,
+		noCreate: noCreate,
 	}
 	err = credfile.loadFile()
 	if err != nil {
@@ -155,154 +177,147 @@ type CredFile struct {
 	filename string
 	ents     []*credEntry
 	currBuff *bytes.Buffer
-	reSep    *regexp.Regexp // regex cred anchor separator e.g. "[\w*]"
+	lockOpts LockOptions
+	snap     fileSnapshot // what the file looked like when we last read or wrote it
+	policy   ModificationPolicy
+	backups  int // number of backups to keep, 0 disables them
+	permOpts PermissionOptions
+	names    NamePolicy
+	prefix   string      // section header prefix, 'profile ' for config files
+	config   *ConfigFile // where region and output go, see SetConfigFile
+	store    *CredFile   // where CredentialProcess keys go, see SetProcessStore
+	known    nameSet     // names in the file and the queue, see checkName
+	creds    *CredFile   // where a config file looks for source profiles, see SetCredFile
+	noCreate bool        // a missing file is an error, see OpenCredFile
 }
 
 type credEntry struct {
 	name     string
 	contents []string
+	absent   bool              // asserting removes the profile instead of writing it
+	values   map[string]string // keys merged into an existing config file profile
 }
 
-// addEntry adds a new credentials entry to the queue
-// to be written or deleted with the AssertEntries or
-// DeleteEntries method.
-func (c *CredFile) addEntry(entryName string, entryContents []string) {
-	e := credEntry{name: entryName, contents: entryContents}
-	c.ents = append(c.ents, &e)
-}
-
-// AssertEntries loops through all of the credEntry objs
-// attached to CredFile obj and makes sure there is an
-// occurrence with the credEntry.name and contents.
-// Existing entries of the same name with different
-// contents will be clobbered.
+// AssertEntries makes sure there is an occurrence of
+// every credEntry attached to the CredFile obj with the
+// credEntry.name and contents. Existing entries of the
+// same name with different contents will be clobbered.
+// All entries are applied in a single write. If a ConfigFile or
+// process store is attached their entries are asserted at the same
+// time.
 func (c *CredFile) AssertEntries() (err error) {
-	for _, e := range c.ents {
-		err = c.modifyEntry(true, e)
-		if err != nil {
-			return err
-		}
+	// keys go in the store first so a stub never points at nothing
+	err = c.updateStore(true)
+	if err != nil {
+		return err
 	}
-	return err
+	return c.withConfig(
+		func() error { return c.modifyEntries(true, c.ents) },
+		func() error { return c.config.file.applyOps(c.config.entryOps(true)) },
+	)
 }
 
-// DeleteEntries loops through all of the credEntry
-// objs attached to CredFile obj and makes sure entries
-// with the same credEntry.name are removed. Will remove
-// ALL entries with the same name.
+// DeleteEntries makes sure entries with the same
+// credEntry.name as any credEntry attached to the CredFile
+// obj are removed. Will remove ALL entries with the same
+// name. All entries are removed in a single write. If a ConfigFile
+// or process store is attached the same profiles are removed from
+// them too.
 func (c *CredFile) DeleteEntries() (err error) {
-	for _, e := range c.ents {
-		err = c.modifyEntry(false, e)
-		if err != nil {
-			return err
-		}
-	}
-	return err
-}
-
-func (e *credEntry) appendToList(lister []string) []string {
-	lister = append(lister, e.name)
-	for _, line := range e.contents {
-		lister = append(lister, line)
+	err = c.withConfig(
+		func() error { return c.modifyEntries(false, c.ents) },
+		func() error { return c.config.file.modifyEntries(false, c.ents) },
+	)
+	if err != nil {
+		return err
 	}
-	return lister
+	return c.updateStore(false)
 }
 
 func (c *CredFile) loadFile() error {
-	if !c.fileExists() {
+	if !c.noCreate && !c.fileExists() {
 		_, err := c.createFile()
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 	f, err := os.OpenFile(c.filename, os.O_RDONLY, os.ModeAppend)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		c.currBuff.WriteString(scanner.Text() + "\n")
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
 	}
+	c.snap = newSnapshot(info, data)
+	c.currBuff.Write(data)
+	c.rememberNames()
 	return err
 }
 
-func (c *CredFile) writeBufferToFile() error {
-	err := ioutil.WriteFile(c.filename, c.currBuff.Bytes(), 0644)
-	return err
+// reload throws away the buffer and reads the file from disk again
+func (c *CredFile) reload() error {
+	c.currBuff.Reset()
+	return c.loadFile()
 }
 
-// indexOf find the index of a value in an []int
-func indexOf(s []int, e int) (index int) {
-	for index, a := range s {
-		if a == e {
-			return index
-		}
+// writeBufferToFile writes buf to disk and makes it
+// the current buffer once the write succeeds
+func (c *CredFile) writeBufferToFile(buf *bytes.Buffer) error {
+	changed, err := c.modifiedOnDisk()
+	if err != nil {
+		return err
+	}
+	if changed {
+		return ErrConcurrentModification
+	}
+	err = c.checkPermissionsBeforeWrite()
+	if err != nil {
+		return err
+	}
+	err = c.backupFile()
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(c.filename, buf.Bytes(), 0600, c.permOpts.AllowForeignOwner)
+	if err != nil {
+		return err
 	}
-	return -1
+	info, err := os.Stat(c.filename)
+	if err != nil {
+		return err
+	}
+	c.currBuff = buf
+	c.snap = newSnapshot(info, buf.Bytes())
+	c.rememberNames()
+	return err
 }
 
-func (c *CredFile) removeEntry(data []string, anchors []int, entry *credEntry) []string {
-	ignoring := false
-	ignoreUntil := 0
-	var newLines []string
-	for i, line := range data {
-		if line == entry.name {
-			currIndex := indexOf(anchors, i)
-			if (currIndex + 1) >= len(anchors) {
-				// this means it's at EOF
-				ignoreUntil = len(data)
-			} else {
-				ignoreUntil = anchors[currIndex+1]
-			}
-
-			ignoring = true
-		}
-		if !(ignoring && i < ignoreUntil) {
-			newLines = append(newLines, line)
-		}
-	}
-	return newLines
+// modifyEntries applies all of the entries to one parsed copy of the
+// buffer and writes the result once. When replace is true every
+// existing section with a matching name is removed and the entries
+// are appended in queue order. If the same name is queued more than
+// once the last one wins. When replace is false matching sections
+// are just removed.
+func (c *CredFile) modifyEntries(replace bool, ents []*credEntry) (err error) {
+	return c.applyOps(entryOps(replace, ents))
 }
 
-// EnsureEntryExists makes sure that the attached Ent
-// entry exists.
-func (c *CredFile) modifyEntry(replace bool, entry *credEntry) (err error) {
-	found := false
-	// read buffer into []string
-	var lines []string
-	scanner := bufio.NewScanner(c.currBuff)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	// search for entry
-	var anchors []int
-	for i, line := range lines {
-		reMatch := c.reSep.FindAllString(line, -1)
-		if reMatch != nil {
-			anchors = append(anchors, i)
-		}
-		if line == entry.name {
-			found = true
+// entryOps turns queued entries into upsert or delete ops
+func entryOps(replace bool, ents []*credEntry) (ops []txOp) {
+	for _, e := range ents {
+		if replace && !e.absent {
+			ops = append(ops, txOp{kind: opUpsert, entry: e})
+		} else {
+			ops = append(ops, txOp{kind: opDelete, name: sectionName(e.name)})
 		}
 	}
-	switch {
-	case found && replace:
-		lines = c.removeEntry(lines, anchors, entry)
-		// make the credEntry append itself to the results
-		lines = entry.appendToList(lines)
-	case found && !replace:
-		lines = c.removeEntry(lines, anchors, entry)
-	case !found && !replace:
-		// do nothing
-	case !found && replace:
-		lines = entry.appendToList(lines)
-	}
-	// now write []string to buffer adding newlines
-	for _, line := range lines {
-		c.currBuff.WriteString(fmt.Sprintf("%s\n", line))
-	}
-	err = c.writeBufferToFile()
-	return err
+	return ops
 }
 
 func (c *CredFile) fileExists() bool {
@@ -314,11 +329,20 @@ func (c *CredFile) fileExists() bool {
 }
 
 func (c *CredFile) createFile() (bool, error) {
-	_, err := os.Create(c.filename)
+	// only create the parent if it's missing so we
+	// don't change the mode of an existing directory
+	dir := filepath.Dir(c.filename)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return false, err
+		}
+	}
+	f, err := os.OpenFile(c.filename, os.O_CREATE|os.O_WRONLY, 0600)
 	if err != nil {
 		return false, err
 	}
-	return true, err
+	return true, f.Close()
 }
 
 // ProfileEntryInput holds properties required
@@ -326,14 +350,22 @@ func (c *CredFile) createFile() (bool, error) {
 // to the file.
 type ProfileEntryInput struct {
 	Credential       *aws.Credentials   // MANDATORY: credentials object from aws
-	ProfileEntryName string             // MANDATORY: name of the desired profile entry e.g., '[devaccount]'. Brackets will be removed and spaces converted to dashes.
-	Region           string             // OPTIONAL: region to include in the profile entry
-	OutputFormat     string             // OPTIONAL: format for output when this credential is used, e.g., ('json', 'text')
+	ProfileEntryName string             // MANDATORY: name of the desired profile entry e.g., '[devaccount]'. Checked and cleaned up by the CredFile's NamePolicy.
+	Region           string             // OPTIONAL: region to include in the profile entry, goes to the ConfigFile if one is attached
+	OutputFormat     string             // OPTIONAL: format for output when this credential is used, e.g., ('json', 'text'), goes to the ConfigFile if one is attached
 	ExpiresToken     string             // OPTIONAL: a token so that string parsers can find the expiry date later
 	InstanceRoleARN  string             // OPTIONAL: the ARN of the Instance Profile Role used to get these credentials
 	AssumeRoleARN    string             // OPTIONAL: the ARN of the role that was assumed to get these credentials
     Description      string             // OPTIONAL: a description to give this entry
 	TemplateOverride *template.Template // OPTIONAL: a text/template.Template to override the package default for this entry
+	CredentialProcess bool              // OPTIONAL: keep the keys in the process store and write a 'credential_process = acfmgr process ...' stub instead, see SetProcessStore
+	RoleProfile      bool               // OPTIONAL: write a profile that has the SDK/CLI assume AssumeRoleARN itself instead of writing Credential
+	SourceProfile    string             // OPTIONAL: for RoleProfile, the profile used to assume the role. Must already exist or be queued.
+	CredentialSource string             // OPTIONAL: for RoleProfile, e.g., ('Ec2InstanceMetadata', 'EcsContainer', 'Environment'). Defaults to 'Ec2InstanceMetadata' if InstanceRoleARN is set.
+	MFASerial        string             // OPTIONAL: for RoleProfile, the ARN or serial of the MFA device to prompt for
+	ExternalID       string             // OPTIONAL: for RoleProfile, the external ID the role's trust policy expects
+	RoleSessionName  string             // OPTIONAL: for RoleProfile, the session name to use when assuming the role
+	DurationSeconds  int                // OPTIONAL: for RoleProfile, how long the assumed credentials last, 900 to 43200
 }
 
 type basicCredential struct {
@@ -360,16 +392,57 @@ func (bc *basicCredential) dump() (string, error){
     return string(b), err
 }
 
+// NewEntry renders the ProfileEntryInput and queues it to be
+// written or deleted with AssertEntries or DeleteEntries.
 func (c *CredFile) NewEntry(pfi *ProfileEntryInput) (err error) {
-	// clean up profile name input
-	credName := strings.Replace(pfi.ProfileEntryName, " ", "-", -1)
-	credName = strings.Replace(credName, "[", "", -1)
-	credName = strings.Replace(credName, "]", "", -1)
-	credName = fmt.Sprintf("[%s]", credName)
-	if len(credName) < 1 {
-		err = errors.New("ProfileEntryName cannot be blank")
+	name, err := c.checkName(pfi.ProfileEntryName)
+	if err != nil {
+		return err
+	}
+	if pfi.RoleProfile {
+		err = c.checkRoleProfile(pfi)
+		if err != nil {
+			return err
+		}
+	}
+	if pfi.CredentialProcess {
+		err = c.checkProcessEntry(pfi)
+		if err != nil {
+			return err
+		}
+	}
+	if c.config != nil {
+		c.config.queueEntry(pfi, name)
+		if pfi.RoleProfile {
+			// keys left here would take precedence over the role
+			c.queue(&credEntry{name: fmt.Sprintf("[%s]", name), absent: true})
+			return err
+		}
+		// settings live in the config file so keep them out of here
+		secrets := *pfi
+		secrets.Region = ""
+		secrets.OutputFormat = ""
+		pfi = &secrets
+	}
+	if pfi.CredentialProcess {
+		return c.queueProcessEntry(pfi, name)
+	}
+	e, err := buildEntry(pfi, name)
+	if err != nil {
 		return err
 	}
+	c.queue(e)
+	return err
+}
+
+// buildEntry renders the ProfileEntryInput into a credEntry
+// using a name that has already been through the NamePolicy
+func buildEntry(pfi *ProfileEntryInput, name string) (e *credEntry, err error) {
+	if pfi.RoleProfile {
+		// nothing secret so it's the same as a config entry
+		return buildConfigEntry(pfi, name), err
+	}
+	credName := fmt.Sprintf("[%s]", name)
 	// build basicCredential with defaults unless user specifies
 	var bc basicCredential
 	loc, _ := time.LoadLocation("UTC")
@@ -380,7 +453,7 @@ func (c *CredFile) NewEntry(pfi *ProfileEntryInput) (err error) {
 	}
 
 	if pfi.ExpiresToken == "" {
-		bc.ExpiresToken = "# EXPIRES@"
+		bc.ExpiresToken = defaultExpiresToken
 	} else {
 		bc.ExpiresToken = pfi.ExpiresToken
 	}
@@ -414,18 +487,27 @@ func (c *CredFile) NewEntry(pfi *ProfileEntryInput) (err error) {
 	if pfi.TemplateOverride != nil {
 		err = pfi.TemplateOverride.Execute(buf, bc)
 		if err != nil {
-			return err
+			return e, err
 		}
 	} else {
 		// use package default
 		err = defaultTemplate.Execute(buf, bc)
 		if err != nil {
-			return err
+			return e, err
 		}
 	}
 	credContents := strings.Split(buf.String(), "\n")
-	c.addEntry(credName, credContents)
-	return err
+	e = &credEntry{name: credName, contents: credContents}
+	return e, err
+}
+
+// cleanProfileName removes brackets and converts spaces
+// to dashes in a user provided profile name
+func cleanProfileName(name string) string {
+	name = strings.Replace(name, " ", "-", -1)
+	name = strings.Replace(name, "[", "", -1)
+	name = strings.Replace(name, "]", "", -1)
+	return name
 }
 
 // expandPath takes a file path as a string and attempts to
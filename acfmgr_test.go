@@ -4,6 +4,7 @@ import (
     "bytes"
     "io/ioutil"
     "os"
+	"path/filepath"
 	"strings"
     "testing"
 	"os/user"
@@ -221,6 +222,24 @@ func gimmeUserMac() (fakeUser *user.User) {
         return fakeUser
 }
 
+func TestLoadFileError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the parent is a file so the credentials file can't be opened
+	parent := filepath.Join(dir, "notadir")
+	err = ioutil.WriteFile(parent, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewCredFileSession(filepath.Join(parent, "credentials"))
+	if err == nil {
+		t.Errorf("Expected an error opening a file under a regular file")
+	}
+}
+
 func TestExpandPath(t *testing.T) {
         cases := []struct {
                 Path                  string
@@ -8,6 +8,7 @@ import (
         "path/filepath"
         "runtime"
         "strings"
+        "syscall"
 )
 
 // expandPathO takes the path as a string and attempts to massage
@@ -35,4 +36,55 @@ func expandPathO(path string, usr *user.User) (expandedPath string, err error) {
         return expandedPath, err
 }
 
+// copyOwnerO makes the file at path owned by the same uid/gid as
+// described in info. Only root can give files away so EPERM is
+// ignored when the owner is already correct.
+func copyOwnerO(path string, info os.FileInfo) error {
+        stat, ok := info.Sys().(*syscall.Stat_t)
+        if !ok {
+                return nil
+        }
+        err := os.Chown(path, int(stat.Uid), int(stat.Gid))
+        if err != nil && int(stat.Uid) == os.Getuid() {
+                // same user, different group we aren't in. not worth failing over
+                return nil
+        }
+        return err
+}
 
+// syncDirO fsyncs the directory so a rename inside it is durable
+func syncDirO(dir string) error {
+        d, err := os.Open(dir)
+        if err != nil {
+                return err
+        }
+        defer d.Close()
+        return d.Sync()
+}
+
+// tryFlockO attempts a non-blocking exclusive flock on f
+func tryFlockO(f *os.File) (bool, error) {
+        err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+        switch err {
+        case nil:
+                return true, nil
+        case syscall.EWOULDBLOCK:
+                return false, nil
+        case syscall.ENOSYS, syscall.EOPNOTSUPP, syscall.ENOLCK:
+                return false, errFlockUnsupported
+        }
+        return false, err
+}
+
+func unlockFlockO(f *os.File) error {
+        return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// fileOwnerO returns the uid that owns the file described by info
+func fileOwnerO(info os.FileInfo) (uid int, ok bool) {
+        stat, ok := info.Sys().(*syscall.Stat_t)
+        if !ok {
+                return 0, false
+        }
+        return int(stat.Uid), true
+}
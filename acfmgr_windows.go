@@ -43,3 +43,29 @@ func expandPathO(path string, usr *user.User) (expandedPath string, err error) {
         return expandedPath, err
 }
 
+// copyOwnerO is a no-op on windows where ownership comes
+// from the ACL of the parent directory
+func copyOwnerO(path string, info os.FileInfo) error {
+        return nil
+}
+
+// syncDirO is a no-op on windows since directories can't be fsynced
+func syncDirO(dir string) error {
+        return nil
+}
+
+// tryFlockO always reports flock as unsupported on windows
+// so the lock file fallback gets used
+func tryFlockO(f *os.File) (bool, error) {
+        return false, errFlockUnsupported
+}
+
+func unlockFlockO(f *os.File) error {
+        return nil
+}
+
+// fileOwnerO isn't supported on windows where ownership
+// is part of the ACL
+func fileOwnerO(info os.FileInfo) (uid int, ok bool) {
+        return 0, false
+}
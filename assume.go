@@ -0,0 +1,87 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// the range AWS allows for AssumeRole DurationSeconds
+const (
+	minAssumeDuration = minRoleDuration * time.Second
+	maxAssumeDuration = maxRoleDuration * time.Second
+)
+
+// ErrInvalidAssumeRole is returned when an AssumeRoleInput
+// is missing settings or has ones STS would reject.
+var ErrInvalidAssumeRole = errors.New("invalid assume role input")
+
+// STSAPI is the part of the STS client AssumeRoleToProfile needs.
+// An aws-sdk-go v1 *sts.STS satisfies it and fakests.Client is an
+// offline stand-in for tests.
+type STSAPI interface {
+	AssumeRoleWithContext(ctx awsv1.Context, input *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error)
+}
+
+// AssumeRoleInput holds what AssumeRoleToProfile needs to
+// assume a role and write the result to a profile.
+type AssumeRoleInput struct {
+	RoleARN         string            // MANDATORY: the role to assume, also written as the entry's AssumeRoleARN
+	RoleSessionName string            // MANDATORY: the session name to assume the role with
+	Duration        time.Duration     // OPTIONAL: how long the credentials last, 15m to 12h. Defaults to the role's setting.
+	ExternalID      string            // OPTIONAL: the external ID the role's trust policy expects
+	Entry           ProfileEntryInput // MANDATORY: ProfileEntryName and any other options used when writing. Credential and AssumeRoleARN are filled in.
+}
+
+// AssumeRoleToProfile assumes the role with client and writes the
+// credentials to the entry's profile with NewEntry and AssertEntries,
+// so anything already queued is written too. Returns the credentials
+// so they can be used straight away.
+func (c *CredFile) AssumeRoleToProfile(ctx context.Context, client STSAPI, in *AssumeRoleInput) (creds *aws.Credentials, err error) {
+	switch {
+	case in.RoleARN == "":
+		return creds, fmt.Errorf("%w: RoleARN is required", ErrInvalidAssumeRole)
+	case in.RoleSessionName == "":
+		return creds, fmt.Errorf("%w: RoleSessionName is required", ErrInvalidAssumeRole)
+	case in.Duration != 0 && (in.Duration < minAssumeDuration || in.Duration > maxAssumeDuration):
+		return creds, fmt.Errorf("%w: Duration must be between %s and %s", ErrInvalidAssumeRole, minAssumeDuration, maxAssumeDuration)
+	}
+	// don't bother STS if we can't write the result
+	_, err = c.checkName(in.Entry.ProfileEntryName)
+	if err != nil {
+		return creds, err
+	}
+	input := &sts.AssumeRoleInput{
+		RoleArn:         awsv1.String(in.RoleARN),
+		RoleSessionName: awsv1.String(in.RoleSessionName),
+	}
+	if in.Duration != 0 {
+		input.DurationSeconds = awsv1.Int64(int64(in.Duration / time.Second))
+	}
+	if in.ExternalID != "" {
+		input.ExternalId = awsv1.String(in.ExternalID)
+	}
+	out, err := client.AssumeRoleWithContext(ctx, input)
+	if err != nil {
+		return creds, err
+	}
+	creds, err = CredentialsFromSTS(out.Credentials)
+	if err != nil {
+		return creds, err
+	}
+	entry := in.Entry
+	entry.Credential = creds
+	entry.AssumeRoleARN = in.RoleARN
+	err = c.NewEntry(&entry)
+	if err != nil {
+		return creds, err
+	}
+	err = c.AssertEntries()
+	return creds, err
+}
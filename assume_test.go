@@ -0,0 +1,99 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GESkunkworks/acfmgr/fakests"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+)
+
+func TestAssumeRoleToProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	now := time.Date(2020, 1, 8, 13, 3, 2, 0, time.UTC)
+	client := fakests.New()
+	client.Now = func() time.Time { return now }
+	in := &AssumeRoleInput{
+		RoleARN:         "arn:aws:iam::123456789012:role/aj/d-admin",
+		RoleSessionName: "me",
+		Duration:        2 * time.Hour,
+		ExternalID:      "xyz",
+		Entry:           ProfileEntryInput{ProfileEntryName: "admin", Region: "us-east-1"},
+	}
+	creds, err := sess.AssumeRoleToProfile(context.Background(), client, in)
+	if err != nil {
+		t.Fatalf("Error assuming role: %s", err)
+	}
+	if !creds.Expires.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("Unexpected expiry. Have: %s, Want: %s", creds.Expires, now.Add(2*time.Hour))
+	}
+	calls := client.Calls()
+	if len(calls) != 1 || awsv1.Int64Value(calls[0].DurationSeconds) != 7200 || awsv1.StringValue(calls[0].ExternalId) != "xyz" {
+		t.Errorf("Unexpected STS calls: %v", calls)
+	}
+	p, err := sess.GetProfile("admin")
+	if err != nil {
+		t.Fatalf("Error getting profile: %s", err)
+	}
+	md, err := p.Metadata("")
+	if err != nil || md.AssumeRoleARN != in.RoleARN || !md.Expires.Equal(creds.Expires) {
+		t.Errorf("Unexpected metadata: %+v %v", md, err)
+	}
+	if p.Values["aws_access_key_id"] != creds.AccessKeyID || p.Values["region"] != "us-east-1" {
+		t.Errorf("Unexpected profile values: %v", p.Values)
+	}
+}
+
+func TestAssumeRoleToProfileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	client := fakests.New()
+	valid := AssumeRoleInput{RoleARN: "arn:aws:iam::123456789012:role/x", RoleSessionName: "me", Entry: ProfileEntryInput{ProfileEntryName: "x"}}
+	cases := []struct {
+		mod func(in *AssumeRoleInput)
+		err error
+	}{
+		{func(in *AssumeRoleInput) { in.RoleARN = "" }, ErrInvalidAssumeRole},
+		{func(in *AssumeRoleInput) { in.RoleSessionName = "" }, ErrInvalidAssumeRole},
+		{func(in *AssumeRoleInput) { in.Duration = time.Minute }, ErrInvalidAssumeRole},
+		{func(in *AssumeRoleInput) { in.Entry.ProfileEntryName = "" }, ErrNameBlank},
+	}
+	for i, tc := range cases {
+		in := valid
+		tc.mod(&in)
+		_, err = sess.AssumeRoleToProfile(context.Background(), client, &in)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Unexpected error for case %d. Have: %v, Want: %v", i, err, tc.err)
+		}
+	}
+	if len(client.Calls()) != 0 {
+		t.Errorf("STS shouldn't be called for invalid input")
+	}
+	client.Err = errors.New("AccessDenied")
+	if _, err = sess.AssumeRoleToProfile(context.Background(), client, &valid); err != client.Err {
+		t.Errorf("Expected STS error, got: %v", err)
+	}
+	if len(sess.ListProfiles()) != 0 {
+		t.Errorf("Nothing should be written when STS fails")
+	}
+}
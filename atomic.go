@@ -0,0 +1,101 @@
+package acfmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeTemp writes data to the temp file. It's a variable
+// so tests can simulate a failed write.
+var writeTemp = func(f *os.File, data []byte) error {
+	_, err := f.Write(data)
+	return err
+}
+
+// copyOwner is a variable so tests can simulate not
+// being allowed to give the file away
+var copyOwner = copyOwnerO
+
+// writeFileAtomic writes data to a temp file in the same directory
+// as filename, fsyncs it and renames it over the original. If the
+// original exists its mode and owner are kept, otherwise perm is used.
+// On any error the original file is left untouched. If filename is a
+// symlink the file it points to is replaced and the link is kept.
+// Only root can keep another user's ownership, when foreignOK is set
+// and the owner can't be kept the new file is owned by us instead of
+// failing.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode, foreignOK bool) (err error) {
+	filename, err = resolveTarget(filename)
+	if err != nil {
+		return err
+	}
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	info, statErr := os.Stat(filename)
+	if statErr == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(dir, "."+base+".acfmgr-tmp-")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+	err = writeTemp(tmp, data)
+	if err != nil {
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+	err = tmp.Chmod(perm)
+	if err != nil {
+		return err
+	}
+	if statErr == nil {
+		err = copyOwner(tmpName, info)
+		if err != nil && !foreignOK {
+			return err
+		}
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpName, filename)
+	if err != nil {
+		return err
+	}
+	return syncDirO(dir)
+}
+
+// resolveTarget follows symlinks so the file they point
+// to gets replaced instead of the link itself
+func resolveTarget(filename string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filename)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	// a dangling link still says where the file should go
+	target, lerr := os.Readlink(filename)
+	if lerr != nil {
+		// not a link, the file just doesn't exist yet
+		return filename, nil
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(filename), target)
+	}
+	return target, nil
+}
@@ -0,0 +1,136 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(filename, []byte(baseCredFile), 0600)
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	err = writeFileAtomic(filename, []byte("[new]\n"), 0644, false)
+	if err != nil {
+		t.Fatalf("Error writing atomically: %s", err)
+	}
+	got, _ := ioutil.ReadFile(filename)
+	if string(got) != "[new]\n" {
+		t.Errorf("Unexpected contents: %s", got)
+	}
+	info, _ := os.Stat(filename)
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Mode not kept. Have: %s", info.Mode().Perm())
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the credentials file in dir, have %d entries", len(entries))
+	}
+}
+
+func TestWriteFileAtomicFollowsSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	// e.g., ~/.aws/credentials linked into a dotfiles repo
+	err = os.Mkdir(filepath.Join(dir, "dotfiles"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "dotfiles", "credentials")
+	err = ioutil.WriteFile(target, []byte(baseCredFile), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "credentials")
+	err = os.Symlink(filepath.Join("dotfiles", "credentials"), link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeFileAtomic(link, []byte("[new]\n"), 0600, false)
+	if err != nil {
+		t.Fatalf("Error writing atomically: %s", err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Symlink was replaced: %v %v", info, err)
+	}
+	got, _ := ioutil.ReadFile(target)
+	if string(got) != "[new]\n" {
+		t.Errorf("Target not written. Have: %s", got)
+	}
+	entries, _ := ioutil.ReadDir(filepath.Join(dir, "dotfiles"))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the credentials file in target dir, have %d entries", len(entries))
+	}
+	// a dangling link gets its target created
+	err = os.Remove(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeFileAtomic(link, []byte("[again]\n"), 0600, false)
+	if err != nil {
+		t.Fatalf("Error writing through dangling link: %s", err)
+	}
+	got, _ = ioutil.ReadFile(target)
+	if string(got) != "[again]\n" {
+		t.Errorf("Dangling link target not written. Have: %s", got)
+	}
+}
+
+func TestWriteFailureLeavesOriginal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	pfi := ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "acfmgrtest"}
+	err = sess.NewEntry(&pfi)
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	// simulate the disk filling up half way through the write
+	diskFull := errors.New("no space left on device")
+	orig := writeTemp
+	writeTemp = func(f *os.File, data []byte) error {
+		f.Write(data[:len(data)/2])
+		return diskFull
+	}
+	defer func() { writeTemp = orig }()
+	err = sess.AssertEntries()
+	if !errors.Is(err, diskFull) {
+		t.Errorf("Expected simulated write error, got: %v", err)
+	}
+	got, _ := ioutil.ReadFile(filename)
+	if string(got) != baseCredFile {
+		t.Errorf("Original file was modified. Have: %s", got)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Temp file left behind, have %d entries", len(entries))
+	}
+}
@@ -0,0 +1,130 @@
+package acfmgr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupInfix separates the credentials filename from
+// the backup timestamp e.g., 'credentials.acfmgr-backup.20200109T223007.527022000Z'
+const backupInfix = ".acfmgr-backup."
+
+// backupTimeFormat sorts lexically in time order
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// ErrBackupNotFound is returned by Restore when there's
+// no backup with the given ID.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup describes a copy of the credentials file taken
+// before it was overwritten.
+type Backup struct {
+	ID      string    // pass to Restore to roll back to this backup
+	Path    string    // full path of the backup file
+	Created time.Time // when the backup was taken
+	Size    int64
+}
+
+// SetBackups makes the CredFile keep the previous contents of the file
+// as up to n timestamped backups next to the original before every
+// write. Backups are only readable by the owner. Zero disables backups.
+func (c *CredFile) SetBackups(n int) {
+	c.backups = n
+}
+
+// ListBackups returns the backups of the credentials file
+// newest first.
+func (c *CredFile) ListBackups() (backups []*Backup, err error) {
+	// not a glob since the path can have '[', '*' or '?' in it
+	dir, base := filepath.Split(c.filename)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return backups, err
+	}
+	prefix := base + backupInfix
+	for _, info := range entries {
+		if !info.Mode().IsRegular() || !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(info.Name(), prefix)
+		created, err := time.Parse(backupTimeFormat, id)
+		if err != nil {
+			// not one of ours
+			continue
+		}
+		path := c.filename + backupInfix + id
+		backups = append(backups, &Backup{ID: id, Path: path, Created: created, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+	return backups, nil
+}
+
+// Restore overwrites the credentials file with the given backup.
+// If backups are enabled the current contents are backed up first
+// so a restore can itself be undone.
+func (c *CredFile) Restore(backupID string) (err error) {
+	backups, err := c.ListBackups()
+	if err != nil {
+		return err
+	}
+	var found *Backup
+	for _, b := range backups {
+		if b.ID == backupID {
+			found = b
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("%w: %s", ErrBackupNotFound, backupID)
+	}
+	data, err := ioutil.ReadFile(found.Path)
+	if err != nil {
+		return err
+	}
+	return c.withLock(func() error {
+		return c.writeBufferToFile(bytes.NewBuffer(data))
+	})
+}
+
+// backupFile copies the current file on disk to a new backup
+// and removes the oldest ones past the limit
+func (c *CredFile) backupFile() error {
+	if c.backups <= 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.filename)
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		// nothing worth keeping
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	id := time.Now().UTC().Format(backupTimeFormat)
+	err = writeFileAtomic(c.filename+backupInfix+id, data, 0600, false)
+	if err != nil {
+		return err
+	}
+	backups, err := c.ListBackups()
+	if err != nil {
+		return err
+	}
+	for i := c.backups; i < len(backups); i++ {
+		err = os.Remove(backups[i].Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupsAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	sess.SetBackups(2)
+	for _, name := range []string{"one", "two", "three"} {
+		tx := sess.Begin()
+		err = tx.Upsert(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: name})
+		if err != nil {
+			t.Fatalf("Error queueing: %s", err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			t.Fatalf("Error committing: %s", err)
+		}
+		// make sure timestamps differ on coarse clocks
+		time.Sleep(time.Millisecond)
+	}
+	backups, err := sess.ListBackups()
+	if err != nil {
+		t.Fatalf("Error listing backups: %s", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups to be kept, have %d", len(backups))
+	}
+	if !backups[0].Created.After(backups[1].Created) {
+		t.Errorf("Backups not sorted newest first")
+	}
+	info, _ := os.Stat(backups[0].Path)
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Backup not locked down. Have: %s", info.Mode().Perm())
+	}
+	// oldest kept backup only has 'one'
+	err = sess.Restore(backups[1].ID)
+	if err != nil {
+		t.Fatalf("Error restoring: %s", err)
+	}
+	if _, err := sess.GetProfile("one"); err != nil {
+		t.Errorf("Restored file is missing profile one")
+	}
+	if _, err := sess.GetProfile("two"); err == nil {
+		t.Errorf("Restored file should not have profile two")
+	}
+	err = sess.Restore("nope")
+	if !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("Expected ErrBackupNotFound, got: %v", err)
+	}
+}
+
+func TestBackupsWithGlobCharacters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cred[s]")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	// as a glob 'cred[s]' matches this instead of our backups
+	decoy := filepath.Join(dir, "creds"+backupInfix+time.Now().UTC().Format(backupTimeFormat))
+	err = ioutil.WriteFile(decoy, []byte("[decoy]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	sess.SetBackups(1)
+	for _, name := range []string{"one", "two"} {
+		err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: name})
+		if err != nil {
+			t.Fatalf("Error adding entry: %s", err)
+		}
+		err = sess.AssertEntries()
+		if err != nil {
+			t.Fatalf("Error asserting entries: %s", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	backups, err := sess.ListBackups()
+	if err != nil {
+		t.Fatalf("Error listing backups: %s", err)
+	}
+	if len(backups) != 1 || filepath.Dir(backups[0].Path) != dir || !strings.HasPrefix(filepath.Base(backups[0].Path), "cred[s]"+backupInfix) {
+		t.Fatalf("Unexpected backups: %+v", backups)
+	}
+	err = sess.Restore(backups[0].ID)
+	if err != nil {
+		t.Fatalf("Error restoring: %s", err)
+	}
+	if _, err = sess.GetProfile("one"); err != nil {
+		t.Errorf("Restored file is missing profile one")
+	}
+	if _, err = os.Stat(decoy); err != nil {
+		t.Errorf("Rotation removed someone else's file: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package acfmgr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertEntriesLastQueuedWins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	sess := assertProfiles(t, filename,
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dupe", Region: "us-east-1"},
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "other"},
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dupe", Region: "us-west-2"},
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "testing"},
+	)
+	var names []string
+	for _, p := range sess.ListProfiles() {
+		names = append(names, p.Name)
+	}
+	want := "newentry,other,dupe,testing"
+	if strings.Join(names, ",") != want {
+		t.Errorf("Unexpected profiles. Have: %v, Want: %s", names, want)
+	}
+	p, _ := sess.GetProfile("dupe")
+	if p.Values["region"] != "us-west-2" {
+		t.Errorf("Expected last queued entry to win. Have region: %s", p.Values["region"])
+	}
+}
+
+// makeBigCredFile writes a credentials file with n hand written sections
+func makeBigCredFile(b *testing.B, filename string, n int) {
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "[account%d]\naws_access_key_id = AKIA%d\naws_secret_access_key = secret%d\n\n", i, i, i)
+	}
+	err := ioutil.WriteFile(filename, buf.Bytes(), 0600)
+	if err != nil {
+		b.Fatalf("Error writing file: %s", err)
+	}
+}
+
+func benchmarkModify(b *testing.B, sections, entries int, replace bool) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		b.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		makeBigCredFile(b, filename, sections)
+		sess, err := NewCredFileSession(filename)
+		if err != nil {
+			b.Fatalf("Error making credfile session: %s", err)
+		}
+		for j := 0; j < entries; j++ {
+			// every other entry replaces an existing section
+			pfi := ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: fmt.Sprintf("account%d", j*2)}
+			err = sess.NewEntry(&pfi)
+			if err != nil {
+				b.Fatalf("Error adding entry: %s", err)
+			}
+		}
+		b.StartTimer()
+		if replace {
+			err = sess.AssertEntries()
+		} else {
+			err = sess.DeleteEntries()
+		}
+		if err != nil {
+			b.Fatalf("Error applying entries: %s", err)
+		}
+	}
+}
+
+func BenchmarkAssertEntries1000x300(b *testing.B) { benchmarkModify(b, 1000, 300, true) }
+func BenchmarkAssertEntries5000x300(b *testing.B) { benchmarkModify(b, 5000, 300, true) }
+func BenchmarkDeleteEntries5000x300(b *testing.B) { benchmarkModify(b, 5000, 300, false) }
+func BenchmarkAssertEntries10000x1(b *testing.B)  { benchmarkModify(b, 10000, 1, true) }
@@ -0,0 +1,190 @@
+package acfmgr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// BulkOptions control how RunManifest calls STS.
+type BulkOptions struct {
+	Parallelism int           // how many roles to assume at once
+	Retries     int           // extra attempts for throttling and other retryable errors
+	Backoff     time.Duration // delay before the first retry, doubled for each one after
+	MaxBackoff  time.Duration // the longest delay between retries
+}
+
+// DefaultBulkOptions are used by RunManifest for any option
+// left at zero.
+var DefaultBulkOptions = BulkOptions{
+	Parallelism: 8,
+	Retries:     3,
+	Backoff:     200 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// BulkResult is the outcome for a single ManifestEntry.
+type BulkResult struct {
+	Profile  string    // the profile from the manifest
+	Attempts int       // how many times STS was called
+	Expires  time.Time // when the written credentials expire
+	Err      error     // nil if the profile was written
+}
+
+// RunManifest assumes every role in the manifest through client, at
+// most opts.Parallelism at a time, retrying retryable errors with
+// exponential backoff. Every success is written in a single commit
+// and failures, including entries that can't be rendered, are
+// reported in the matching BulkResult without stopping the others.
+// err is only set if the write fails, in which case nothing was
+// written and every result carries the error. With a ConfigFile
+// attached region and output go to it in the same commit.
+func (c *CredFile) RunManifest(ctx context.Context, client STSAPI, m *Manifest, opts BulkOptions) (results []BulkResult, err error) {
+	err = m.validate()
+	if err != nil {
+		return results, err
+	}
+	opts = opts.withDefaults()
+	results = make([]BulkResult, len(m.Profiles))
+	entries := make([]*ProfileEntryInput, len(m.Profiles))
+	sem := make(chan struct{}, opts.Parallelism)
+	var wg sync.WaitGroup
+	for i := range m.Profiles {
+		results[i].Profile = m.Profiles[i].Profile
+		// don't bother STS if we can't write the result
+		if _, err := c.checkName(m.Profiles[i].Profile); err != nil {
+			results[i].Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+				return
+			}
+			entries[i], results[i] = assumeEntry(ctx, client, m, i, opts)
+		}(i)
+	}
+	wg.Wait()
+	tx := c.Begin()
+	var written []int
+	for i, pfi := range entries {
+		if pfi == nil || results[i].Err != nil {
+			continue
+		}
+		if err := tx.Upsert(pfi); err != nil {
+			results[i].Err = err
+			// one bad entry shouldn't stop the others being written
+			tx.err = nil
+			continue
+		}
+		written = append(written, i)
+	}
+	if len(written) == 0 {
+		tx.Rollback()
+		return results, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		// nothing was written so none of them succeeded
+		for _, i := range written {
+			results[i].Err = err
+		}
+	}
+	return results, err
+}
+
+// withDefaults fills in zero options from DefaultBulkOptions
+func (o BulkOptions) withDefaults() BulkOptions {
+	if o.Parallelism <= 0 {
+		o.Parallelism = DefaultBulkOptions.Parallelism
+	}
+	if o.Retries == 0 {
+		o.Retries = DefaultBulkOptions.Retries
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultBulkOptions.Backoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultBulkOptions.MaxBackoff
+	}
+	return o
+}
+
+// assumeEntry assumes the role for entry i with retries and
+// builds the ProfileEntryInput to write
+func assumeEntry(ctx context.Context, client STSAPI, m *Manifest, i int, opts BulkOptions) (pfi *ProfileEntryInput, res BulkResult) {
+	e := m.Profiles[i]
+	res.Profile = e.Profile
+	input := &sts.AssumeRoleInput{
+		RoleArn:         awsv1.String(e.RoleARN),
+		RoleSessionName: awsv1.String(DefaultSessionName),
+	}
+	if m.SessionName != "" {
+		input.RoleSessionName = awsv1.String(m.SessionName)
+	}
+	if m.DurationSeconds != 0 {
+		input.DurationSeconds = awsv1.Int64(int64(m.DurationSeconds))
+	}
+	if e.ExternalID != "" {
+		input.ExternalId = awsv1.String(e.ExternalID)
+	}
+	var out *sts.AssumeRoleOutput
+	delay := opts.Backoff
+	for {
+		res.Attempts++
+		out, res.Err = client.AssumeRoleWithContext(ctx, input)
+		if res.Err == nil || res.Attempts > opts.Retries || !retryable(res.Err) {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			res.Err = fmt.Errorf("%v (gave up retrying: %w)", res.Err, ctx.Err())
+			return pfi, res
+		}
+		delay *= 2
+		if delay > opts.MaxBackoff {
+			delay = opts.MaxBackoff
+		}
+	}
+	if res.Err != nil {
+		return pfi, res
+	}
+	creds, err := CredentialsFromSTS(out.Credentials)
+	if err != nil {
+		res.Err = err
+		return pfi, res
+	}
+	res.Expires = creds.Expires
+	pfi = &ProfileEntryInput{
+		Credential:       creds,
+		ProfileEntryName: e.Profile,
+		AssumeRoleARN:    e.RoleARN,
+		Region:           e.Region,
+		OutputFormat:     e.Output,
+		Description:      e.Description,
+	}
+	return pfi, res
+}
+
+// retryable reports whether STS might succeed if asked again
+func retryable(err error) bool {
+	return request.IsErrorThrottle(err) || request.IsErrorRetryable(err)
+}
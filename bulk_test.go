@@ -0,0 +1,235 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GESkunkworks/acfmgr/fakests"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestRunManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	sess := assertProfiles(t, filename)
+	m := &Manifest{SessionName: "oncall"}
+	for i := 0; i < 40; i++ {
+		m.Profiles = append(m.Profiles, ManifestEntry{
+			Profile: fmt.Sprintf("acct%02d", i),
+			RoleARN: fmt.Sprintf("arn:aws:iam::1234567890%02d:role/admin", i),
+			Region:  "us-east-1",
+			Output:  "json",
+		})
+	}
+	m.Profiles[5].RoleARN = "arn:aws:iam::123456789005:role/denied"
+	m.Profiles[6].Profile = "default"
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	client := fakests.New()
+	client.Hook = func(in *sts.AssumeRoleInput, call int) error {
+		switch {
+		case awsv1.StringValue(in.RoleArn) == m.Profiles[5].RoleARN:
+			return awserr.New("AccessDenied", "not allowed", nil)
+		case call < 3:
+			return awserr.New("Throttling", "Rate exceeded", nil)
+		}
+		return nil
+	}
+	counting := &countingSTS{STSAPI: client, mu: &mu, running: &running, max: &maxRunning}
+	results, err := sess.RunManifest(context.Background(), counting, m, BulkOptions{Parallelism: 4, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error running manifest: %s", err)
+	}
+	if maxRunning > 4 {
+		t.Errorf("Too many concurrent calls. Have: %d, Want: <= 4", maxRunning)
+	}
+	for i, res := range results {
+		switch i {
+		case 5:
+			if res.Err == nil || res.Attempts != 1 {
+				t.Errorf("Expected AccessDenied without retries, got: %+v", res)
+			}
+		case 6:
+			if !errors.Is(res.Err, ErrNameReserved) || res.Attempts != 0 {
+				t.Errorf("Expected ErrNameReserved without calling STS, got: %+v", res)
+			}
+		default:
+			if res.Err != nil || res.Attempts != 3 || res.Expires.IsZero() {
+				t.Errorf("Expected success after 2 retries, got: %+v", res)
+			}
+		}
+	}
+	profiles := sess.ListProfiles()
+	// the base file has its own profiles
+	if len(profiles) != 38+len(parseProfiles(parseDocument([]byte(baseCredFile)))) {
+		t.Errorf("Unexpected number of profiles: %d", len(profiles))
+	}
+	p, err := sess.GetProfile("acct00")
+	if err != nil || p.Values["region"] != "us-east-1" || p.Values["output"] != "json" {
+		t.Errorf("Unexpected profile: %v %v", p, err)
+	}
+	md, _ := p.Metadata("")
+	if md.AssumeRoleARN != m.Profiles[0].RoleARN {
+		t.Errorf("Unexpected AssumeRoleARN. Have: %s", md.AssumeRoleARN)
+	}
+}
+
+func TestRunManifestRetriesExhausted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	client := fakests.New()
+	client.Hook = func(in *sts.AssumeRoleInput, call int) error {
+		return awserr.New("Throttling", "Rate exceeded", nil)
+	}
+	m := &Manifest{Profiles: []ManifestEntry{{Profile: "dev", RoleARN: "arn:aws:iam::123456789012:role/x"}}}
+	results, err := sess.RunManifest(context.Background(), client, m, BulkOptions{Retries: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error running manifest: %s", err)
+	}
+	if results[0].Err == nil || results[0].Attempts != 3 {
+		t.Errorf("Expected failure after 3 attempts, got: %+v", results[0])
+	}
+	if len(sess.ListProfiles()) != 0 {
+		t.Errorf("Nothing should be written when everything fails")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, _ = sess.RunManifest(ctx, client, m, BulkOptions{Backoff: time.Hour})
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", results[0].Err)
+	}
+}
+
+// countingSTS tracks how many calls are in flight at once
+type countingSTS struct {
+	STSAPI
+	mu           *sync.Mutex
+	running, max *int
+}
+
+func (c *countingSTS) AssumeRoleWithContext(ctx awsv1.Context, in *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error) {
+	c.mu.Lock()
+	*c.running++
+	if *c.running > *c.max {
+		*c.max = *c.running
+	}
+	c.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	defer func() {
+		c.mu.Lock()
+		*c.running--
+		c.mu.Unlock()
+	}()
+	return c.STSAPI.AssumeRoleWithContext(ctx, in, opts...)
+}
+
+func TestRunManifestBadEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	m := &Manifest{Profiles: []ManifestEntry{
+		{Profile: "good", RoleARN: "arn:aws:iam::123456789012:role/x", Output: "json"},
+		{Profile: "bad", RoleARN: "arn:aws:iam::123456789012:role/y"},
+	}}
+	// the second entry can't be rendered
+	sess.SetNamePolicy(&rejectOnSecondCall{})
+	results, err := sess.RunManifest(context.Background(), fakests.New(), m, BulkOptions{})
+	if err != nil {
+		t.Fatalf("Error running manifest: %s", err)
+	}
+	if results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("Expected only the bad entry to fail, got: %+v", results)
+	}
+	p, err := sess.GetProfile("good")
+	if err != nil || p.Values["output"] != "json" {
+		t.Errorf("Expected good to be written, got: %v %v", p, err)
+	}
+}
+
+// rejectOnSecondCall passes the up front name check for every
+// entry but rejects "bad" when it's queued
+type rejectOnSecondCall struct {
+	calls map[string]int
+}
+
+func (r *rejectOnSecondCall) CheckName(name string, existing []string) (string, error) {
+	if r.calls == nil {
+		r.calls = make(map[string]int)
+	}
+	r.calls[name]++
+	if name == "bad" && r.calls[name] > 1 {
+		return "", &NameError{Name: name, Reason: ErrNameInvalid}
+	}
+	return name, nil
+}
+
+func TestRunManifestWithConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	cf, err := NewConfigFileSession(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	sess.SetConfigFile(cf)
+	m := &Manifest{Profiles: []ManifestEntry{
+		{Profile: "dev", RoleARN: "arn:aws:iam::123456789012:role/admin", Region: "us-east-2", Output: "json"},
+		{Profile: "prod", RoleARN: "arn:aws:iam::210987654321:role/admin"},
+	}}
+	results, err := sess.RunManifest(context.Background(), fakests.New(), m, BulkOptions{})
+	if err != nil {
+		t.Fatalf("Error running manifest: %s", err)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("Unexpected error for %s: %s", res.Profile, res.Err)
+		}
+	}
+	for _, name := range []string{"dev", "prod"} {
+		p, err := sess.GetProfile(name)
+		if err != nil || p.Values["region"] != "" || p.Values["output"] != "" || p.Values["aws_secret_access_key"] == "" {
+			t.Errorf("Unexpected credentials profile: %v %v", p, err)
+		}
+	}
+	p, err := cf.GetProfile("dev")
+	if err != nil || p.Values["region"] != "us-east-2" || p.Values["output"] != "json" {
+		t.Errorf("Unexpected config profile: %v %v", p, err)
+	}
+	if _, err = cf.GetProfile("prod"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected no config profile without settings, got: %v", err)
+	}
+}
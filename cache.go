@@ -0,0 +1,96 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// DefaultExpiryWindow is how long before expiry the CachingProvider
+// considers cached credentials stale if no window is given.
+const DefaultExpiryWindow = 5 * time.Minute
+
+// CachingProvider wraps an upstream aws.CredentialsProvider (e.g., an
+// STS assume role provider) and uses a managed profile in a credentials
+// file as a shared cache. Retrieve returns the cached profile while it's
+// still valid and only calls upstream when it's missing or close to
+// expiry, writing the fresh credentials back to the file. This lets
+// several short lived processes share a single session.
+//
+// Upstream credentials that can't expire are still written but will
+// never be considered valid from the cache.
+type CachingProvider struct {
+	Upstream     aws.CredentialsProvider // MANDATORY: where to get fresh credentials
+	Filename     string                  // MANDATORY: credentials file to use as the cache
+	Entry        ProfileEntryInput       // MANDATORY: ProfileEntryName and any other options used when writing. Credential is ignored.
+	ExpiryWindow time.Duration           // OPTIONAL: refresh this long before expiry, defaults to DefaultExpiryWindow
+	mu           sync.Mutex
+}
+
+// Retrieve returns the cached credentials from the file if they
+// are still valid, otherwise it calls upstream and writes the result
+// back to the file. The cache is checked again under the file lock so
+// when several processes find it stale only one goes upstream.
+func (cp *CachingProvider) Retrieve(ctx context.Context) (creds aws.Credentials, err error) {
+	if cp.Upstream == nil {
+		return creds, errors.New("CachingProvider requires an Upstream provider")
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	c, err := NewCredFileSession(cp.Filename)
+	if err != nil {
+		return creds, err
+	}
+	if cached, ok := cp.cached(c); ok {
+		return cached, err
+	}
+	var fresh *aws.Credentials
+	err = c.withLock(func() error {
+		// someone else may have refreshed it while we waited
+		if cached, ok := cp.cached(c); ok {
+			creds = cached
+			return nil
+		}
+		if fresh == nil {
+			up, err := cp.Upstream.Retrieve(ctx)
+			if err != nil {
+				return err
+			}
+			fresh = &up
+		}
+		creds = *fresh
+		entry := cp.Entry
+		entry.Credential = fresh
+		c.ents = nil
+		err := c.NewEntry(&entry)
+		if err != nil {
+			return err
+		}
+		return c.modifyEntries(true, c.ents)
+	})
+	return creds, err
+}
+
+// cached returns the credentials from the managed profile in the
+// file if there is one and it's not within the expiry window
+func (cp *CachingProvider) cached(c *CredFile) (creds aws.Credentials, ok bool) {
+	p, err := c.GetProfile(cleanProfileName(cp.Entry.ProfileEntryName))
+	if err != nil || !p.Managed {
+		return creds, false
+	}
+	creds, err = p.Credentials(cp.Entry.ExpiresToken)
+	if err != nil || !creds.CanExpire {
+		return creds, false
+	}
+	window := cp.ExpiryWindow
+	if window == 0 {
+		window = DefaultExpiryWindow
+	}
+	if !creds.Expires.After(time.Now().Add(window)) {
+		return creds, false
+	}
+	return creds, true
+}
@@ -0,0 +1,124 @@
+package acfmgr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// countingProvider hands out getFakeCreds with a configurable
+// expiry and counts how many times it's called
+type countingProvider struct {
+	mu      sync.Mutex
+	calls   int
+	expires time.Time
+	delay   time.Duration // how long upstream takes
+}
+
+func (p *countingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	creds := *getFakeCreds()
+	creds.CanExpire = true
+	creds.Expires = p.expires
+	return creds, nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	filename := "./acfmgr_credfile_test_cache.txt"
+	err := writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	defer os.Remove(filename)
+	upstream := &countingProvider{expires: time.Now().Add(time.Hour).UTC().Truncate(time.Second)}
+	newProvider := func() *CachingProvider {
+		return &CachingProvider{
+			Upstream: upstream,
+			Filename: filename,
+			Entry:    ProfileEntryInput{ProfileEntryName: "cached profile", Region: "us-east-2"},
+		}
+	}
+	creds, err := newProvider().Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Error retrieving: %s", err)
+	}
+	if upstream.calls != 1 {
+		t.Errorf("Expected 1 upstream call, have %d", upstream.calls)
+	}
+	// a second "process" should be served from the file
+	cached, err := newProvider().Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Error retrieving: %s", err)
+	}
+	if upstream.calls != 1 {
+		t.Errorf("Expected cached result, upstream called %d times", upstream.calls)
+	}
+	if cached.SessionToken != creds.SessionToken || !cached.Expires.Equal(creds.Expires) {
+		t.Errorf("Cached creds don't match. Have: %+v, Want: %+v", cached, creds)
+	}
+	// inside the expiry window we should go upstream again
+	cp := newProvider()
+	cp.ExpiryWindow = 2 * time.Hour
+	_, err = cp.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Error retrieving: %s", err)
+	}
+	if upstream.calls != 2 {
+		t.Errorf("Expected refresh inside window, upstream called %d times", upstream.calls)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	if len(sess.ListProfiles()) != 3 {
+		t.Errorf("Expected a single cached section, have %d profiles", len(sess.ListProfiles()))
+	}
+}
+
+func TestCachingProviderSharedAcrossProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	upstream := &countingProvider{expires: time.Now().Add(time.Hour).UTC().Truncate(time.Second), delay: 20 * time.Millisecond}
+	// separate providers stand in for separate processes
+	start := make(chan struct{})
+	errs := make(chan error, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		cp := &CachingProvider{
+			Upstream: upstream,
+			Filename: filename,
+			Entry:    ProfileEntryInput{ProfileEntryName: "shared"},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := cp.Retrieve(context.Background())
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Error retrieving: %s", err)
+		}
+	}
+	if upstream.calls != 1 {
+		t.Errorf("Unexpected upstream calls. Have: %d, Want: 1", upstream.calls)
+	}
+}
@@ -0,0 +1,234 @@
+// Command acfmgr works with credentials files managed by the
+// acfmgr package.
+//
+// Print a profile as credential_process JSON for the AWS SDKs and CLI:
+//
+//  acfmgr process --profile devaccount [--file ~/.aws/credentials] [--expires-token TOKEN]
+//
+// Profiles written with ProfileEntryInput.CredentialProcess get a
+// credential_process stub that runs this for them.
+//
+// Print a profile as environment variables:
+//
+//  acfmgr export --profile devaccount [--format bash|fish|powershell|dotenv|docker|json] [--file ~/.aws/credentials]
+//
+// e.g., eval "$(acfmgr export --profile devaccount)"
+//
+// Write credentials from the environment, or from credential_process or
+// 'aws sts assume-role' JSON on stdin, to a profile:
+//
+//  acfmgr import --profile ci [--from env|process|assume-role] [--file ~/.aws/credentials]
+//
+// Assume every role in a JSON or YAML manifest using the default AWS
+// credential chain and write them all at once:
+//
+//  acfmgr bulk --manifest roles.yaml [--parallel 8] [--file ~/.aws/credentials]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/GESkunkworks/acfmgr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const usage = `usage: acfmgr <command> [flags]
+
+commands:
+  process    print a profile as credential_process JSON
+  export     print a profile as environment variables
+  import     write credentials from the environment or stdin to a profile
+  bulk       assume every role in a manifest and write the profiles
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command in args and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "process":
+		return process(args[1:], stdout, stderr)
+	case "export":
+		return export(args[1:], stdout, stderr)
+	case "import":
+		return importEntry(args[1:], stdin, stderr)
+	case "bulk":
+		return bulk(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func process(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	profile := fs.String("profile", "", "name of the profile to print (required)")
+	file := fs.String("file", "~/.aws/credentials", "credentials file to read")
+	token := fs.String("expires-token", "", "ExpiresToken used when the profile was written")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *profile == "" {
+		fmt.Fprintln(stderr, "--profile is required")
+		fs.Usage()
+		return 2
+	}
+	err := acfmgr.WriteProcessCredentials(stdout, *file, *profile, *token)
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func export(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	profile := fs.String("profile", "", "name of the profile to print (required)")
+	file := fs.String("file", "~/.aws/credentials", "credentials file to read")
+	format := fs.String("format", "bash", "one of bash, zsh, fish, powershell, dotenv, docker or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *profile == "" {
+		fmt.Fprintln(stderr, "--profile is required")
+		fs.Usage()
+		return 2
+	}
+	f, err := acfmgr.ParseExportFormat(*format)
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 2
+	}
+	c, err := acfmgr.OpenCredFile(*file)
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	out, err := c.ExportProfile(*profile, f)
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	fmt.Fprint(stdout, out)
+	return 0
+}
+
+func importEntry(args []string, stdin io.Reader, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	profile := fs.String("profile", "", "name of the profile to write (required)")
+	file := fs.String("file", "~/.aws/credentials", "credentials file to write")
+	from := fs.String("from", "env", "one of env, process or assume-role, JSON is read from stdin")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *profile == "" {
+		fmt.Fprintln(stderr, "--profile is required")
+		fs.Usage()
+		return 2
+	}
+	var pfi *acfmgr.ProfileEntryInput
+	var err error
+	switch *from {
+	case "env":
+		pfi, err = acfmgr.ProfileEntryInputFromEnv(*profile)
+	case "process", "assume-role":
+		var data []byte
+		data, err = ioutil.ReadAll(stdin)
+		if err != nil {
+			break
+		}
+		if *from == "process" {
+			pfi, err = acfmgr.ProfileEntryInputFromProcessJSON(*profile, data)
+		} else {
+			pfi, err = acfmgr.ProfileEntryInputFromAssumeRoleJSON(*profile, data)
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown --from %q\n", *from)
+		return 2
+	}
+	if err == nil {
+		err = writeEntry(*file, pfi)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeEntry asserts a single entry in the credentials file
+func writeEntry(filename string, pfi *acfmgr.ProfileEntryInput) error {
+	c, err := acfmgr.NewCredFileSession(filename)
+	if err != nil {
+		return err
+	}
+	err = c.NewEntry(pfi)
+	if err != nil {
+		return err
+	}
+	return c.AssertEntries()
+}
+
+func bulk(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bulk", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	manifest := fs.String("manifest", "", "JSON or YAML manifest of profiles and roles (required)")
+	file := fs.String("file", "~/.aws/credentials", "credentials file to write")
+	parallel := fs.Int("parallel", acfmgr.DefaultBulkOptions.Parallelism, "how many roles to assume at once")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *manifest == "" {
+		fmt.Fprintln(stderr, "--manifest is required")
+		fs.Usage()
+		return 2
+	}
+	m, err := acfmgr.LoadManifest(*manifest)
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	c, err := acfmgr.NewCredFileSession(*file)
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	results, err := c.RunManifest(context.Background(), sts.New(sess), m, acfmgr.BulkOptions{Parallelism: *parallel})
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+			fmt.Fprintf(stderr, "%s: %s\n", res.Profile, res.Err)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: expires %s\n", res.Profile, res.Expires)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "acfmgr: %s\n", err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
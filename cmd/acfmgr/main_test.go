@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GESkunkworks/acfmgr"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// writeProfiles writes a fresh and an expired profile
+// to a credentials file in a new temp dir
+func writeProfiles(t *testing.T) (dir, filename string) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename = filepath.Join(dir, "credentials")
+	c, err := acfmgr.NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	creds := []struct {
+		Name    string
+		Expires time.Time
+	}{
+		{"fresh", time.Now().Add(time.Hour).UTC().Truncate(time.Second)},
+		{"expired", time.Now().Add(-time.Hour).UTC().Truncate(time.Second)},
+	}
+	for _, cr := range creds {
+		err = c.NewEntry(&acfmgr.ProfileEntryInput{
+			ProfileEntryName: cr.Name,
+			Credential: &aws.Credentials{
+				AccessKeyID:     "AKID" + cr.Name,
+				SecretAccessKey: "SECRET",
+				SessionToken:    "TOKEN",
+				Expires:         cr.Expires,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error adding entry: %s", err)
+		}
+	}
+	err = c.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	return dir, filename
+}
+
+func TestProcess(t *testing.T) {
+	dir, filename := writeProfiles(t)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"process", "--profile", "fresh", "--file", filename}, nil, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Unexpected exit code. Have: %d, Want: 0 (%s)", code, stderr.String())
+	}
+	var pc acfmgr.ProcessCredentials
+	err := json.Unmarshal(stdout.Bytes(), &pc)
+	if err != nil {
+		t.Fatalf("Error decoding output: %s\n%s", err, stdout.String())
+	}
+	if pc.Version != 1 || pc.AccessKeyID != "AKIDfresh" || pc.SessionToken != "TOKEN" || pc.Expiration == "" {
+		t.Errorf("Unexpected output: %s", stdout.String())
+	}
+}
+
+func TestProcessExitCodes(t *testing.T) {
+	dir, filename := writeProfiles(t)
+	defer os.RemoveAll(dir)
+	missingFile := filepath.Join(dir, "nothere", "credentials")
+	cases := []struct {
+		Args []string
+		Want int
+	}{
+		{[]string{"process", "--profile", "missing", "--file", filename}, 1},
+		{[]string{"process", "--profile", "expired", "--file", filename}, 1},
+		{[]string{"process", "--profile", "fresh", "--file", missingFile}, 1},
+		{[]string{"process", "--file", filename}, 2},
+		{[]string{"process", "--bogus"}, 2},
+		{[]string{"process", "--profile"}, 2},
+		{[]string{"nope"}, 2},
+		{nil, 2},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		code := run(c.Args, nil, &stdout, &stderr)
+		if code != c.Want {
+			t.Errorf("Unexpected exit code for %v. Have: %d, Want: %d", c.Args, code, c.Want)
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("Expected nothing on stdout for %v, got: %s", c.Args, stdout.String())
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("Expected an error on stderr for %v", c.Args)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(missingFile)); !os.IsNotExist(err) {
+		t.Errorf("process created %s", filepath.Dir(missingFile))
+	}
+}
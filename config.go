@@ -0,0 +1,273 @@
+package acfmgr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// configPrefix is put in front of every profile header in
+// ~/.aws/config except for the default profile
+const configPrefix = "profile "
+
+// ConfigFile is a session for an AWS shared config file like
+// ~/.aws/config. Profiles are written under '[profile name]' headers
+// and looked up by their plain name. Only settings are ever written
+// and asserting an entry sets just its keys, everything else already
+// in the section (sso_start_url etc.) is left alone. Build one with
+// NewConfigFileSession.
+type ConfigFile struct {
+	file *CredFile
+}
+
+// NewConfigFileSession creates a new interactive config file
+// session. Needs target filename and returns ConfigFile obj and err.
+func NewConfigFileSession(filename string) (cf *ConfigFile, err error) {
+	c, err := newSession(filename, configPrefix, false)
+	if err != nil {
+		return cf, err
+	}
+	cf = &ConfigFile{file: c}
+	return cf, err
+}
+
+// NewEntry queues the non-secret settings of the ProfileEntryInput,
+// including the role settings of a RoleProfile, to be written or
+// deleted with AssertEntries or DeleteEntries. Credentials are never
+// written to the config file.
+func (cf *ConfigFile) NewEntry(pfi *ProfileEntryInput) (err error) {
+	name, err := cf.file.checkName(pfi.ProfileEntryName)
+	if err != nil {
+		return err
+	}
+	if pfi.RoleProfile {
+		err = cf.file.checkRoleProfile(pfi)
+		if err != nil {
+			return err
+		}
+	}
+	cf.queueEntry(pfi, name)
+	return err
+}
+
+// AssertEntries sets the keys of every queued entry in a single
+// write, adding profiles that aren't in the file yet.
+func (cf *ConfigFile) AssertEntries() (err error) {
+	return cf.file.withLock(func() error {
+		return cf.file.applyOps(cf.entryOps(true))
+	})
+}
+
+// DeleteEntries removes every profile with the same name as a
+// queued entry in a single write.
+func (cf *ConfigFile) DeleteEntries() (err error) {
+	return cf.file.withLock(func() error {
+		return cf.file.applyOps(cf.entryOps(false))
+	})
+}
+
+// Plan returns what AssertEntries or DeleteEntries would
+// do without writing anything.
+func (cf *ConfigFile) Plan(mode PlanMode) (*Plan, error) {
+	return cf.file.planOps(cf.entryOps(mode == PlanAssert))
+}
+
+// ListProfiles returns every profile in the file by its plain name.
+func (cf *ConfigFile) ListProfiles() (profiles []*Profile) {
+	return cf.file.ListProfiles()
+}
+
+// GetProfile returns the profile with the given plain name.
+func (cf *ConfigFile) GetProfile(name string) (*Profile, error) {
+	return cf.file.GetProfile(name)
+}
+
+// Validate checks the file for problems, see CredFile.Validate.
+func (cf *ConfigFile) Validate() (findings []Finding) {
+	return cf.file.Validate()
+}
+
+// SetLockOptions overrides the default locking behavior.
+func (cf *ConfigFile) SetLockOptions(opts LockOptions) {
+	cf.file.SetLockOptions(opts)
+}
+
+// SetModificationPolicy sets what happens when the file has
+// been changed by someone else since it was read.
+func (cf *ConfigFile) SetModificationPolicy(p ModificationPolicy) {
+	cf.file.SetModificationPolicy(p)
+}
+
+// SetBackups sets how many backups of the file to keep.
+func (cf *ConfigFile) SetBackups(n int) {
+	cf.file.SetBackups(n)
+}
+
+// SetPermissionOptions overrides the default permission checks.
+func (cf *ConfigFile) SetPermissionOptions(opts PermissionOptions) {
+	cf.file.SetPermissionOptions(opts)
+}
+
+// SetNamePolicy sets the NamePolicy used for new profile names.
+func (cf *ConfigFile) SetNamePolicy(p NamePolicy) {
+	cf.file.SetNamePolicy(p)
+}
+
+// queueEntry queues the settings for a profile name that's already
+// been through the NamePolicy. Entries without any settings aren't
+// queued so we don't leave empty sections behind.
+func (cf *ConfigFile) queueEntry(pfi *ProfileEntryInput, name string) {
+	e := buildConfigEntry(pfi, name)
+	if len(e.contents) > 0 {
+		cf.file.queue(e)
+	}
+}
+
+// entryOps turns queued entries into merge or delete ops
+func (cf *ConfigFile) entryOps(replace bool) (ops []txOp) {
+	for _, e := range cf.file.ents {
+		if replace {
+			ops = append(ops, txOp{kind: opMerge, name: sectionName(e.name), entry: e})
+		} else {
+			ops = append(ops, txOp{kind: opDelete, name: sectionName(e.name)})
+		}
+	}
+	return ops
+}
+
+// buildConfigEntry renders the settings that belong in the config
+// file into a credEntry. contents is the section for a new profile
+// and values are the keys merged into an existing one. Region and
+// output are only set when given but all of the role keys are set
+// so stale ones from an earlier role are removed.
+func buildConfigEntry(pfi *ProfileEntryInput, name string) *credEntry {
+	e := &credEntry{name: fmt.Sprintf("[%s]", name), values: make(map[string]string)}
+	if pfi.Region != "" {
+		e.contents = append(e.contents, "region = "+pfi.Region)
+		e.values["region"] = pfi.Region
+	}
+	if pfi.OutputFormat != "" {
+		e.contents = append(e.contents, "output = "+pfi.OutputFormat)
+		e.values["output"] = pfi.OutputFormat
+	}
+	if pfi.RoleProfile {
+		for _, k := range roleKeys {
+			e.values[k] = ""
+		}
+		for _, line := range roleLines(pfi) {
+			e.contents = append(e.contents, line)
+			kv := strings.SplitN(line, " = ", 2)
+			e.values[kv[0]] = kv[1]
+		}
+	}
+	return e
+}
+
+// ConfigTx is a set of changes to a ConfigFile that are applied
+// together with Commit or thrown away with Rollback. Build one with
+// ConfigFile.Begin.
+type ConfigTx struct {
+	tx *Tx
+}
+
+// Begin starts a new transaction against the ConfigFile.
+func (cf *ConfigFile) Begin() *ConfigTx {
+	return &ConfigTx{tx: cf.file.Begin()}
+}
+
+// Set queues setting the non-secret settings of the
+// ProfileEntryInput the same way AssertEntries does.
+func (t *ConfigTx) Set(pfi *ProfileEntryInput) (err error) {
+	c := t.tx.c
+	name, err := c.checkName(pfi.ProfileEntryName)
+	if err == nil && pfi.RoleProfile {
+		err = c.checkRoleProfile(pfi)
+	}
+	if err != nil {
+		if t.tx.err == nil {
+			t.tx.err = err
+		}
+		return err
+	}
+	e := buildConfigEntry(pfi, name)
+	if len(e.contents) > 0 {
+		t.tx.ops = append(t.tx.ops, txOp{kind: opMerge, name: name, entry: e})
+	}
+	return err
+}
+
+// Delete queues removal of every profile with the given name.
+func (t *ConfigTx) Delete(name string) {
+	t.tx.Delete(name)
+}
+
+// Rename queues renaming the profile, see Tx.Rename.
+func (t *ConfigTx) Rename(from, to string) {
+	t.tx.Rename(from, to)
+}
+
+// Patch queues setting keys on an existing profile, see Tx.Patch.
+func (t *ConfigTx) Patch(name string, values map[string]string) {
+	t.tx.Patch(name, values)
+}
+
+// Plan returns what Commit would do without writing anything.
+func (t *ConfigTx) Plan() (*Plan, error) {
+	return t.tx.Plan()
+}
+
+// Commit applies all of the queued changes in one write.
+func (t *ConfigTx) Commit() (err error) {
+	return t.tx.Commit()
+}
+
+// Rollback drops all of the queued changes.
+func (t *ConfigTx) Rollback() {
+	t.tx.Rollback()
+}
+
+// SetConfigFile attaches a ConfigFile to the CredFile. Once attached
+// NewEntry sends region and output to the config file and keeps
+// secrets in the credentials file, and AssertEntries and DeleteEntries
+// update both files together. Pass nil to detach it.
+func (c *CredFile) SetConfigFile(cf *ConfigFile) {
+	if c.config != nil && c.config.file.creds == c {
+		c.config.file.creds = nil
+	}
+	c.config = cf
+	if cf != nil {
+		cf.SetCredFile(c)
+	}
+}
+
+// SetCredFile links the credentials file that goes with the
+// ConfigFile so a RoleProfile's SourceProfile can be found in
+// either one. SetConfigFile links them automatically.
+func (cf *ConfigFile) SetCredFile(c *CredFile) {
+	cf.file.creds = c
+}
+
+// withConfig runs credFn under the credentials file lock and then
+// configFn under the config file lock while still holding the first
+// one. If the config file can't be written the credentials file is
+// put back the way it was so the two don't disagree.
+func (c *CredFile) withConfig(credFn, configFn func() error) (err error) {
+	if c.config == nil {
+		return c.withLock(credFn)
+	}
+	return c.withLock(func() error {
+		before := append([]byte{}, c.currBuff.Bytes()...)
+		err := credFn()
+		if err != nil {
+			return err
+		}
+		err = c.config.file.withLock(configFn)
+		if err != nil && !bytes.Equal(before, c.currBuff.Bytes()) {
+			rerr := c.writeBufferToFile(bytes.NewBuffer(before))
+			if rerr != nil {
+				return fmt.Errorf("%v (restoring %s also failed: %v)", err, c.filename, rerr)
+			}
+		}
+		return err
+	})
+}
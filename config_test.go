@@ -0,0 +1,254 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const baseConfigFile = `[default]
+region = us-east-1
+
+[profile handwritten]
+region = eu-west-1
+output = table
+
+[sso-session corp]
+sso_region = us-east-1
+`
+
+func TestConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(filename, []byte(baseConfigFile), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := NewConfigFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	p, err := cf.GetProfile("handwritten")
+	if err != nil || p.Values["output"] != "table" {
+		t.Errorf("Expected to find handwritten by plain name, got: %v %v", p, err)
+	}
+	err = cf.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-west-2", OutputFormat: "json"})
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = cf.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	data, _ := ioutil.ReadFile(filename)
+	if !strings.HasPrefix(string(data), baseConfigFile) {
+		t.Errorf("Existing sections were changed:\n%s", data)
+	}
+	if !strings.Contains(string(data), "\n[profile dev]\nregion = us-west-2\noutput = json\n") {
+		t.Errorf("Expected [profile dev] section:\n%s", data)
+	}
+	if strings.Contains(string(data), "aws_secret_access_key") {
+		t.Errorf("Secrets written to config file:\n%s", data)
+	}
+	tx := cf.Begin()
+	tx.Rename("handwritten", "renamed")
+	tx.Patch("default", map[string]string{"output": "json"})
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	data, _ = ioutil.ReadFile(filename)
+	if !strings.Contains(string(data), "[profile renamed]\n") || !strings.HasPrefix(string(data), "[default]\nregion = us-east-1\noutput = json\n") {
+		t.Errorf("Unexpected rename or patch result:\n%s", data)
+	}
+	err = cf.DeleteEntries()
+	if err != nil {
+		t.Fatalf("Error deleting entries: %s", err)
+	}
+	if _, err = cf.GetProfile("dev"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected dev to be deleted, got: %v", err)
+	}
+}
+
+func TestCredFileWithConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	credName := filepath.Join(dir, "credentials")
+	confName := filepath.Join(dir, "config")
+	sess, err := NewCredFileSession(credName)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	cf, err := NewConfigFileSession(confName)
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	sess.SetConfigFile(cf)
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-west-2", OutputFormat: "json"})
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	p, err := sess.GetProfile("dev")
+	if err != nil {
+		t.Fatalf("Error getting credentials profile: %s", err)
+	}
+	if p.Values["region"] != "" || p.Values["output"] != "" || p.Values["aws_secret_access_key"] == "" {
+		t.Errorf("Unexpected credentials profile values: %v", p.Values)
+	}
+	p, err = cf.GetProfile("dev")
+	if err != nil {
+		t.Fatalf("Error getting config profile: %s", err)
+	}
+	if p.Values["region"] != "us-west-2" || p.Values["output"] != "json" {
+		t.Errorf("Unexpected config profile values: %v", p.Values)
+	}
+	err = sess.DeleteEntries()
+	if err != nil {
+		t.Fatalf("Error deleting entries: %s", err)
+	}
+	if len(sess.ListProfiles()) != 0 || len(cf.ListProfiles()) != 0 {
+		t.Errorf("Expected both files to be empty")
+	}
+}
+
+func TestConfigFileRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	credName := filepath.Join(dir, "credentials")
+	sess := assertProfiles(t, credName)
+	before, _ := ioutil.ReadFile(credName)
+	cf, err := NewConfigFileSession(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	sess.SetConfigFile(cf)
+	cf.SetModificationPolicy(FailOnModification)
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-west-2"})
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	// someone else edits the config file so writing it fails
+	err = ioutil.WriteFile(filepath.Join(dir, "config"), []byte("[default]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.AssertEntries()
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("Expected ErrConcurrentModification, got: %v", err)
+	}
+	after, _ := ioutil.ReadFile(credName)
+	if string(after) != string(before) {
+		t.Errorf("Credentials file wasn't restored. Have:\n%s\nWant:\n%s", after, before)
+	}
+}
+
+func TestConfigFileKeepsOtherKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	confName := filepath.Join(dir, "config")
+	existing := "[profile dev]\nsso_start_url = https://corp.awsapps.com/start\nregion = us-east-1\n"
+	err = ioutil.WriteFile(confName, []byte(existing), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	cf, err := NewConfigFileSession(confName)
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	sess.SetConfigFile(cf)
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-west-2", OutputFormat: "json"})
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	data, _ := ioutil.ReadFile(confName)
+	want := "[profile dev]\nsso_start_url = https://corp.awsapps.com/start\nregion = us-west-2\noutput = json\n"
+	if string(data) != want {
+		t.Errorf("Unexpected config file. Have:\n%s\nWant:\n%s", data, want)
+	}
+	// a transaction only ever sets the settings too
+	tx := cf.Begin()
+	err = tx.Set(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", OutputFormat: "text"})
+	if err != nil {
+		t.Fatalf("Error setting entry: %s", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	data, _ = ioutil.ReadFile(confName)
+	want = strings.Replace(want, "output = json", "output = text", 1)
+	if string(data) != want {
+		t.Errorf("Unexpected config file. Have:\n%s\nWant:\n%s", data, want)
+	}
+}
+
+func TestTxWithConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	cf, err := NewConfigFileSession(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	sess.SetConfigFile(cf)
+	tx := sess.Begin()
+	err = tx.Upsert(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-east-2", OutputFormat: "json"})
+	if err != nil {
+		t.Fatalf("Error upserting: %s", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	p, err := sess.GetProfile("dev")
+	if err != nil || p.Values["region"] != "" || p.Values["output"] != "" || p.Values["aws_secret_access_key"] == "" {
+		t.Errorf("Unexpected credentials profile: %v %v", p, err)
+	}
+	p, err = cf.GetProfile("dev")
+	if err != nil || p.Values["region"] != "us-east-2" || p.Values["output"] != "json" {
+		t.Errorf("Unexpected config profile: %v %v", p, err)
+	}
+	tx = sess.Begin()
+	tx.Delete("dev")
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	if len(sess.ListProfiles()) != 0 || len(cf.ListProfiles()) != 0 {
+		t.Errorf("Expected both files to be empty")
+	}
+}
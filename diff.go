@@ -0,0 +1,207 @@
+package acfmgr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// secretKeys are the keys whose values are masked in diffs
+var secretKeys = []string{"aws_secret_access_key", "aws_session_token"}
+
+// diffLine is one line of an edit script. kind is ' ' for
+// lines in both, '-' for removed and '+' for added lines.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// diffLines returns the shortest edit script turning a into b using
+// the Myers algorithm. Common leading and trailing lines are trimmed
+// first since most changes only touch a few sections.
+func diffLines(a, b []string) (script []diffLine) {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	for _, line := range a[:pre] {
+		script = append(script, diffLine{' ', line})
+	}
+	script = append(script, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		script = append(script, diffLine{' ', line})
+	}
+	return script
+}
+
+func myers(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v[-d..d] as it was before round d
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v[off-d:off+d+1]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+	// walk back through the trace to build the script
+	var rev []diffLine
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		prev := func(k int) int {
+			if k < -d || k > d {
+				return 0
+			}
+			return trace[d][k+d]
+		}
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, diffLine{'+', b[y-1]})
+				y--
+			} else {
+				rev = append(rev, diffLine{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+	script := make([]diffLine, len(rev))
+	for i := range rev {
+		script[i] = rev[len(rev)-1-i]
+	}
+	return script
+}
+
+// unifiedDiff renders a unified diff between the two file contents.
+// Returns an empty string if they're the same.
+func unifiedDiff(name string, before, after []byte, redact bool) string {
+	a := splitLines(before)
+	b := splitLines(after)
+	script := diffLines(a, b)
+	var changes []int
+	for i, l := range script {
+		if l.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "--- a/%s\n+++ b/%s\n", name, name)
+	// line numbers in a and b at the start of each script entry
+	aLine := make([]int, len(script)+1)
+	bLine := make([]int, len(script)+1)
+	for i, l := range script {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.kind != '+' {
+			aLine[i+1]++
+		}
+		if l.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+	for i := 0; i < len(changes); {
+		start := changes[i] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// grow the hunk while the next change is close enough
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext {
+			j++
+		}
+		end := changes[j] + diffContext + 1
+		if end > len(script) {
+			end = len(script)
+		}
+		aCount := aLine[end] - aLine[start]
+		bCount := bLine[end] - bLine[start]
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aLine[start], aCount), hunkRange(bLine[start], bCount))
+		for _, l := range script[start:end] {
+			text := l.text
+			if redact {
+				text = redactLine(text)
+			}
+			fmt.Fprintf(out, "%c%s\n", l.kind, text)
+		}
+		i = j + 1
+	}
+	return out.String()
+}
+
+// hunkRange formats the start,count part of a hunk header
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// redactLine masks the value if the line sets one of the secretKeys
+func redactLine(line string) string {
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return line
+	}
+	key := strings.TrimSpace(kv[0])
+	for _, secret := range secretKeys {
+		if key == secret {
+			return fmt.Sprintf("%s = ********", key)
+		}
+	}
+	return line
+}
@@ -0,0 +1,159 @@
+package acfmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExportFormat picks the syntax ExportProfile uses.
+type ExportFormat int
+
+const (
+	// ExportShell writes bash/zsh 'export KEY='value'' lines.
+	ExportShell ExportFormat = iota
+	// ExportFish writes fish 'set -x KEY 'value'' lines.
+	ExportFish
+	// ExportPowerShell writes '$env:KEY = 'value'' lines.
+	ExportPowerShell
+	// ExportDotenv writes 'KEY='value'' lines for .env files.
+	ExportDotenv
+	// ExportDocker writes unquoted 'KEY=value' lines for docker --env-file.
+	ExportDocker
+	// ExportJSON writes a JSON object of the variables.
+	ExportJSON
+)
+
+// exportFormatNames are the names ParseExportFormat accepts
+var exportFormatNames = map[string]ExportFormat{
+	"bash":       ExportShell,
+	"zsh":        ExportShell,
+	"sh":         ExportShell,
+	"fish":       ExportFish,
+	"powershell": ExportPowerShell,
+	"pwsh":       ExportPowerShell,
+	"dotenv":     ExportDotenv,
+	"docker":     ExportDocker,
+	"json":       ExportJSON,
+}
+
+// ErrUnknownExportFormat is returned for formats
+// ExportProfile and ParseExportFormat don't know.
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// ParseExportFormat returns the ExportFormat for a name like
+// 'bash', 'fish', 'powershell', 'dotenv', 'docker' or 'json'.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	f, ok := exportFormatNames[strings.ToLower(name)]
+	if !ok {
+		return f, fmt.Errorf("%w: %s", ErrUnknownExportFormat, name)
+	}
+	return f, nil
+}
+
+// exportVar is a single environment variable
+type exportVar struct {
+	name, value string
+}
+
+// ExportProfile returns the profile as environment variables in the
+// given format: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, when the
+// profile has them, AWS_SESSION_TOKEN, AWS_REGION and
+// AWS_CREDENTIAL_EXPIRATION (RFC3339 from the managed header). The
+// region comes from the attached ConfigFile if the profile has none.
+func (c *CredFile) ExportProfile(name string, format ExportFormat) (out string, err error) {
+	p, err := c.GetProfile(name)
+	if err != nil {
+		return out, err
+	}
+	creds, err := p.Credentials("")
+	if err != nil {
+		return out, err
+	}
+	vars := []exportVar{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+	}
+	if creds.SessionToken != "" {
+		vars = append(vars, exportVar{"AWS_SESSION_TOKEN", creds.SessionToken})
+	}
+	region := p.Values["region"]
+	if region == "" && c.config != nil {
+		if cp, err := c.config.GetProfile(p.Name); err == nil {
+			region = cp.Values["region"]
+		}
+	}
+	if region != "" {
+		vars = append(vars, exportVar{"AWS_REGION", region})
+	}
+	if creds.CanExpire {
+		vars = append(vars, exportVar{"AWS_CREDENTIAL_EXPIRATION", creds.Expires.UTC().Format(time.RFC3339)})
+	}
+	return formatVars(vars, format)
+}
+
+// formatVars renders the variables quoting the
+// values the way each format needs
+func formatVars(vars []exportVar, format ExportFormat) (string, error) {
+	if format == ExportJSON {
+		m := make(map[string]string)
+		for _, v := range vars {
+			m[v.name] = v.value
+		}
+		b, err := json.MarshalIndent(m, "", "  ")
+		return string(b) + "\n", err
+	}
+	var b strings.Builder
+	for _, v := range vars {
+		switch format {
+		case ExportShell:
+			fmt.Fprintf(&b, "export %s=%s\n", v.name, shellQuote(v.value))
+		case ExportFish:
+			fmt.Fprintf(&b, "set -x %s %s\n", v.name, fishQuote(v.value))
+		case ExportPowerShell:
+			fmt.Fprintf(&b, "$env:%s = %s\n", v.name, powerShellQuote(v.value))
+		case ExportDotenv:
+			fmt.Fprintf(&b, "%s=%s\n", v.name, dotenvQuote(v.value))
+		case ExportDocker:
+			// docker doesn't unquote anything so the value has to be one plain line
+			if strings.ContainsAny(v.value, "\r\n") {
+				return "", fmt.Errorf("%s can't be written to a docker env file: value has a newline", v.name)
+			}
+			fmt.Fprintf(&b, "%s=%s\n", v.name, v.value)
+		default:
+			return "", fmt.Errorf("%w: %d", ErrUnknownExportFormat, format)
+		}
+	}
+	return b.String(), nil
+}
+
+// shellQuote single quotes s for POSIX shells, closing
+// and reopening the quotes around embedded single quotes
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote single quotes s for fish where only
+// backslash and single quote can be escaped
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+// powerShellQuote single quotes s for PowerShell
+// where a single quote is escaped by doubling it
+func powerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// dotenvQuote single quotes s so it isn't interpolated, falling back to
+// double quotes with escapes when s has characters single quotes can't hold
+func dotenvQuote(s string) string {
+	if !strings.ContainsAny(s, "'\r\n") {
+		return "'" + s + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	return `"` + r.Replace(s) + `"`
+}
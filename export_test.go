@@ -0,0 +1,86 @@
+package acfmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess := assertProfiles(t, filepath.Join(dir, "credentials"),
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-east-2"},
+	)
+	fake := getFakeCreds()
+	want := map[ExportFormat]string{
+		ExportShell: "export AWS_ACCESS_KEY_ID='" + fake.AccessKeyID + "'\n" +
+			"export AWS_SECRET_ACCESS_KEY='" + fake.SecretAccessKey + "'\n" +
+			"export AWS_SESSION_TOKEN='" + fake.SessionToken + "'\n" +
+			"export AWS_REGION='us-east-2'\n" +
+			"export AWS_CREDENTIAL_EXPIRATION='2020-01-08T14:03:02Z'\n",
+		ExportDocker: "AWS_ACCESS_KEY_ID=" + fake.AccessKeyID + "\n" +
+			"AWS_SECRET_ACCESS_KEY=" + fake.SecretAccessKey + "\n" +
+			"AWS_SESSION_TOKEN=" + fake.SessionToken + "\n" +
+			"AWS_REGION=us-east-2\n" +
+			"AWS_CREDENTIAL_EXPIRATION=2020-01-08T14:03:02Z\n",
+	}
+	for format, w := range want {
+		have, err := sess.ExportProfile("dev", format)
+		if err != nil {
+			t.Fatalf("Error exporting: %s", err)
+		}
+		if have != w {
+			t.Errorf("Unexpected export for format %d. Have:\n%s\nWant:\n%s", format, have, w)
+		}
+	}
+	out, err := sess.ExportProfile("dev", ExportJSON)
+	if err != nil {
+		t.Fatalf("Error exporting: %s", err)
+	}
+	var m map[string]string
+	if err = json.Unmarshal([]byte(out), &m); err != nil || m["AWS_SESSION_TOKEN"] != fake.SessionToken {
+		t.Errorf("Unexpected JSON export: %v %s", err, out)
+	}
+	if _, err = sess.ExportProfile("missing", ExportShell); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got: %v", err)
+	}
+	if _, err = sess.ExportProfile("dev", ExportFormat(99)); !errors.Is(err, ErrUnknownExportFormat) {
+		t.Errorf("Expected ErrUnknownExportFormat, got: %v", err)
+	}
+}
+
+func TestExportQuoting(t *testing.T) {
+	vars := []exportVar{{"V", `a'b\c$d`}}
+	cases := map[ExportFormat]string{
+		ExportShell:      `export V='a'\''b\c$d'` + "\n",
+		ExportFish:       `set -x V 'a\'b\\c$d'` + "\n",
+		ExportPowerShell: `$env:V = 'a''b\c$d'` + "\n",
+		ExportDotenv:     `V="a'b\\c\$d"` + "\n",
+		ExportDocker:     `V=a'b\c$d` + "\n",
+	}
+	for format, want := range cases {
+		have, err := formatVars(vars, format)
+		if err != nil {
+			t.Fatalf("Error formatting: %s", err)
+		}
+		if have != want {
+			t.Errorf("Unexpected quoting for format %d. Have: %s, Want: %s", format, have, want)
+		}
+	}
+	if have, _ := formatVars([]exportVar{{"V", "plain$"}}, ExportDotenv); have != "V='plain$'\n" {
+		t.Errorf("Expected single quoted dotenv value. Have: %s", have)
+	}
+	if _, err := formatVars([]exportVar{{"V", "a\nb"}}, ExportDocker); err == nil {
+		t.Errorf("Expected error for newline in docker env file")
+	}
+	if f, err := ParseExportFormat("ZSH"); err != nil || f != ExportShell {
+		t.Errorf("Unexpected ParseExportFormat result: %v %v", f, err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package fakests is an offline stand-in for the STS client used by
+// acfmgr.AssumeRoleToProfile so callers can unit test without AWS.
+//
+//	client := fakests.New()
+//	creds, err := c.AssumeRoleToProfile(ctx, client, &acfmgr.AssumeRoleInput{...})
+package fakests
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// DefaultDuration is used when the input has no DurationSeconds,
+// the same as the default for a real role.
+const DefaultDuration = time.Hour
+
+// Client hands out made up credentials for any role. It's
+// safe for concurrent use.
+type Client struct {
+	Now   func() time.Time                                 // OPTIONAL: clock used for Expiration, defaults to time.Now
+	Err   error                                            // OPTIONAL: returned from every call instead of credentials
+	Hook  func(input *sts.AssumeRoleInput, call int) error // OPTIONAL: called with the 1 based call number for the role, a non nil error is returned instead of credentials e.g., to simulate throttling
+	calls []sts.AssumeRoleInput
+	mu    sync.Mutex
+}
+
+// New returns a Client that always succeeds.
+func New() *Client {
+	return &Client{}
+}
+
+// AssumeRoleWithContext returns fresh fake credentials for the role.
+// Like STS it fails on a missing RoleArn or RoleSessionName and when
+// ctx is done.
+func (c *Client) AssumeRoleWithContext(ctx aws.Context, input *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, *input)
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	if c.Hook != nil {
+		call := 0
+		for _, in := range c.calls {
+			if aws.StringValue(in.RoleArn) == aws.StringValue(input.RoleArn) {
+				call++
+			}
+		}
+		if err := c.Hook(input, call); err != nil {
+			return nil, err
+		}
+	}
+	if aws.StringValue(input.RoleArn) == "" || aws.StringValue(input.RoleSessionName) == "" {
+		return nil, awserr.New("ValidationError", "RoleArn and RoleSessionName are required", nil)
+	}
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	duration := DefaultDuration
+	if input.DurationSeconds != nil {
+		duration = time.Duration(*input.DurationSeconds) * time.Second
+	}
+	n := len(c.calls)
+	return &sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(fmt.Sprintf("ASIAFAKE%012d", n)),
+			SecretAccessKey: aws.String(fmt.Sprintf("fakesecret%030d", n)),
+			SessionToken:    aws.String(fmt.Sprintf("fakesessiontoken%d", n)),
+			Expiration:      aws.Time(now().Add(duration).UTC().Truncate(time.Second)),
+		},
+		AssumedRoleUser: &sts.AssumedRoleUser{
+			Arn:           aws.String(assumedRoleARN(aws.StringValue(input.RoleArn), aws.StringValue(input.RoleSessionName))),
+			AssumedRoleId: aws.String(fmt.Sprintf("AROAFAKE%012d:%s", n, aws.StringValue(input.RoleSessionName))),
+		},
+	}, nil
+}
+
+// Calls returns a copy of every input the Client has been called with.
+func (c *Client) Calls() []sts.AssumeRoleInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]sts.AssumeRoleInput{}, c.calls...)
+}
+
+// assumedRoleARN turns arn:aws:iam::123456789012:role/path/name into
+// arn:aws:sts::123456789012:assumed-role/name/session like STS does
+func assumedRoleARN(roleARN, session string) string {
+	parts := strings.SplitN(roleARN, ":", 6)
+	if len(parts) != 6 {
+		return roleARN
+	}
+	name := parts[5][strings.LastIndex(parts[5], "/")+1:]
+	return fmt.Sprintf("arn:%s:sts::%s:assumed-role/%s/%s", parts[1], parts[4], name, session)
+}
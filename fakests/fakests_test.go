@@ -0,0 +1,36 @@
+package fakests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestAssumeRole(t *testing.T) {
+	c := New()
+	out, err := c.AssumeRoleWithContext(context.Background(), &sts.AssumeRoleInput{
+		RoleArn:         aws.String("arn:aws:iam::123456789012:role/aj/d-admin"),
+		RoleSessionName: aws.String("me"),
+	})
+	if err != nil {
+		t.Fatalf("Error assuming role: %s", err)
+	}
+	want := "arn:aws:sts::123456789012:assumed-role/d-admin/me"
+	if aws.StringValue(out.AssumedRoleUser.Arn) != want {
+		t.Errorf("Unexpected assumed role ARN. Have: %s, Want: %s", aws.StringValue(out.AssumedRoleUser.Arn), want)
+	}
+	_, err = c.AssumeRoleWithContext(context.Background(), &sts.AssumeRoleInput{})
+	if err == nil {
+		t.Errorf("Expected ValidationError for missing RoleArn")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = c.AssumeRoleWithContext(ctx, &sts.AssumeRoleInput{}); err == nil {
+		t.Errorf("Expected error for canceled context")
+	}
+	if len(c.Calls()) != 2 {
+		t.Errorf("Unexpected number of calls. Have: %d, Want: 2", len(c.Calls()))
+	}
+}
@@ -6,4 +6,5 @@ require (
 	github.com/aws/aws-sdk-go v1.28.0
 	github.com/aws/aws-sdk-go-v2 v1.17.8
 	golang.org/x/sys v0.7.0
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,167 @@
+package acfmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ImportSource is the value used for aws.Credentials.Source
+// on credentials built by the ProfileEntryInputFrom functions.
+const ImportSource = "AcfmgrImport"
+
+// ProfileEntryInputFromEnv builds a ProfileEntryInput from the
+// environment variables the AWS SDKs read: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION (or
+// AWS_DEFAULT_REGION), AWS_DEFAULT_OUTPUT and AWS_CREDENTIAL_EXPIRATION
+// (RFC3339). Pass it to NewEntry to write it as a named profile.
+func ProfileEntryInputFromEnv(profileName string) (*ProfileEntryInput, error) {
+	return entryFromEnv(profileName, os.Getenv)
+}
+
+// entryFromEnv does the work for ProfileEntryInputFromEnv
+// with a getenv that tests can fake
+func entryFromEnv(profileName string, getenv func(string) string) (pfi *ProfileEntryInput, err error) {
+	// the older names are still honored by the SDKs
+	first := func(names ...string) string {
+		for _, n := range names {
+			if v := getenv(n); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	creds := &aws.Credentials{
+		AccessKeyID:     first("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretAccessKey: first("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		SessionToken:    first("AWS_SESSION_TOKEN", "AWS_SECURITY_TOKEN"),
+		Source:          ImportSource,
+	}
+	if !creds.HasKeys() {
+		return pfi, fmt.Errorf("%w: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set", ErrMissingKeys)
+	}
+	err = setExpiration(creds, getenv("AWS_CREDENTIAL_EXPIRATION"))
+	if err != nil {
+		return pfi, fmt.Errorf("AWS_CREDENTIAL_EXPIRATION: %w", err)
+	}
+	pfi = &ProfileEntryInput{
+		Credential:       creds,
+		ProfileEntryName: profileName,
+		Region:           first("AWS_REGION", "AWS_DEFAULT_REGION"),
+		OutputFormat:     getenv("AWS_DEFAULT_OUTPUT"),
+	}
+	return pfi, err
+}
+
+// ProfileEntryInputFromProcessJSON builds a ProfileEntryInput from
+// the JSON document printed by a credential_process command.
+func ProfileEntryInputFromProcessJSON(profileName string, data []byte) (pfi *ProfileEntryInput, err error) {
+	var pc ProcessCredentials
+	err = json.Unmarshal(data, &pc)
+	if err != nil {
+		return pfi, err
+	}
+	if pc.Version != 1 {
+		return pfi, fmt.Errorf("unsupported credential_process Version %d", pc.Version)
+	}
+	creds := &aws.Credentials{
+		AccessKeyID:     pc.AccessKeyID,
+		SecretAccessKey: pc.SecretAccessKey,
+		SessionToken:    pc.SessionToken,
+		Source:          ImportSource,
+	}
+	if !creds.HasKeys() {
+		return pfi, fmt.Errorf("%w: AccessKeyId and SecretAccessKey are required", ErrMissingKeys)
+	}
+	err = setExpiration(creds, pc.Expiration)
+	if err != nil {
+		return pfi, fmt.Errorf("Expiration: %w", err)
+	}
+	pfi = &ProfileEntryInput{Credential: creds, ProfileEntryName: profileName}
+	return pfi, err
+}
+
+// assumeRoleOutput is the part of the 'aws sts assume-role'
+// output we care about
+type assumeRoleOutput struct {
+	Credentials *struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      string
+	}
+	AssumedRoleUser struct {
+		Arn string
+	}
+}
+
+// ProfileEntryInputFromAssumeRoleJSON builds a ProfileEntryInput from
+// the JSON output of 'aws sts assume-role' (or get-session-token). The
+// assumed role user's ARN is turned back into the role's IAM ARN and
+// recorded as the AssumeRoleARN. The STS ARN doesn't include the role's
+// path so only roles without one (the default '/') come out right,
+// otherwise set AssumeRoleARN yourself.
+func ProfileEntryInputFromAssumeRoleJSON(profileName string, data []byte) (pfi *ProfileEntryInput, err error) {
+	var out assumeRoleOutput
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return pfi, err
+	}
+	if out.Credentials == nil {
+		return pfi, fmt.Errorf("%w: no Credentials in the document", ErrMissingKeys)
+	}
+	creds := &aws.Credentials{
+		AccessKeyID:     out.Credentials.AccessKeyID,
+		SecretAccessKey: out.Credentials.SecretAccessKey,
+		SessionToken:    out.Credentials.SessionToken,
+		Source:          ImportSource,
+	}
+	if !creds.HasKeys() {
+		return pfi, fmt.Errorf("%w: AccessKeyId and SecretAccessKey are required", ErrMissingKeys)
+	}
+	err = setExpiration(creds, out.Credentials.Expiration)
+	if err != nil {
+		return pfi, fmt.Errorf("Expiration: %w", err)
+	}
+	pfi = &ProfileEntryInput{
+		Credential:       creds,
+		ProfileEntryName: profileName,
+		AssumeRoleARN:    roleARN(out.AssumedRoleUser.Arn),
+	}
+	return pfi, err
+}
+
+// roleARN converts an assumed role session ARN like
+// 'arn:aws:sts::123456789012:assumed-role/admin/me' to the ARN of
+// the role, 'arn:aws:iam::123456789012:role/admin'. Anything else
+// returns blank since it can't be assumed again.
+func roleARN(sessionARN string) string {
+	parts := strings.SplitN(sessionARN, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sts" {
+		return ""
+	}
+	res := strings.Split(parts[5], "/")
+	if len(res) != 3 || res[0] != "assumed-role" || res[1] == "" {
+		return ""
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], res[1])
+}
+
+// setExpiration parses an RFC3339 expiry into creds,
+// leaving them non-expiring when it's blank
+func setExpiration(creds *aws.Credentials, expiration string) error {
+	if expiration == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, expiration)
+	if err != nil {
+		return err
+	}
+	creds.CanExpire = true
+	creds.Expires = t.UTC()
+	return nil
+}
@@ -0,0 +1,127 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProfileEntryInputFromEnv(t *testing.T) {
+	fake := getFakeCreds()
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":         fake.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY":     fake.SecretAccessKey,
+		"AWS_SECURITY_TOKEN":        fake.SessionToken,
+		"AWS_DEFAULT_REGION":        "us-east-2",
+		"AWS_DEFAULT_OUTPUT":        "json",
+		"AWS_CREDENTIAL_EXPIRATION": "2020-01-08T14:03:02Z",
+	}
+	getenv := func(k string) string { return env[k] }
+	pfi, err := entryFromEnv("ci", getenv)
+	if err != nil {
+		t.Fatalf("Error importing from env: %s", err)
+	}
+	c := pfi.Credential
+	if c.AccessKeyID != fake.AccessKeyID || c.SecretAccessKey != fake.SecretAccessKey || c.SessionToken != fake.SessionToken {
+		t.Errorf("Unexpected keys: %+v", c)
+	}
+	if !c.CanExpire || !c.Expires.Equal(fake.Expires) {
+		t.Errorf("Unexpected expiry. Have: %s, Want: %s", c.Expires, fake.Expires)
+	}
+	if pfi.Region != "us-east-2" || pfi.OutputFormat != "json" || pfi.ProfileEntryName != "ci" {
+		t.Errorf("Unexpected settings: %+v", pfi)
+	}
+	// the output setting has to survive being written
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess := assertProfiles(t, filepath.Join(dir, "credentials"), *pfi)
+	p, err := sess.GetProfile("ci")
+	if err != nil || p.Values["output"] != "json" || p.Values["region"] != "us-east-2" {
+		t.Errorf("Unexpected profile after writing: %v %v", p, err)
+	}
+	env["AWS_CREDENTIAL_EXPIRATION"] = "tomorrow"
+	if _, err = entryFromEnv("ci", getenv); err == nil {
+		t.Errorf("Expected error for bad AWS_CREDENTIAL_EXPIRATION")
+	}
+	delete(env, "AWS_SECRET_ACCESS_KEY")
+	if _, err = entryFromEnv("ci", getenv); !errors.Is(err, ErrMissingKeys) {
+		t.Errorf("Expected ErrMissingKeys, got: %v", err)
+	}
+}
+
+func TestProfileEntryInputFromJSON(t *testing.T) {
+	process := `{"Version": 1, "AccessKeyId": "AKIA", "SecretAccessKey": "secret", "SessionToken": "token", "Expiration": "2020-01-08T14:03:02+00:00"}`
+	pfi, err := ProfileEntryInputFromProcessJSON("proc", []byte(process))
+	if err != nil {
+		t.Fatalf("Error importing process JSON: %s", err)
+	}
+	if pfi.Credential.AccessKeyID != "AKIA" || !pfi.Credential.Expires.Equal(getFakeCreds().Expires) {
+		t.Errorf("Unexpected credentials: %+v", pfi.Credential)
+	}
+	if _, err = ProfileEntryInputFromProcessJSON("proc", []byte(`{"Version": 2}`)); err == nil {
+		t.Errorf("Expected error for unsupported Version")
+	}
+	assumeRole := `{
+    "Credentials": {
+        "AccessKeyId": "ASIA",
+        "SecretAccessKey": "secret",
+        "SessionToken": "token",
+        "Expiration": "2020-01-08T14:03:02Z"
+    },
+    "AssumedRoleUser": {
+        "AssumedRoleId": "AROA:me",
+        "Arn": "arn:aws:sts::123456789012:assumed-role/admin/me"
+    }
+}`
+	pfi, err = ProfileEntryInputFromAssumeRoleJSON("assumed", []byte(assumeRole))
+	if err != nil {
+		t.Fatalf("Error importing assume-role JSON: %s", err)
+	}
+	if pfi.Credential.SessionToken != "token" || pfi.AssumeRoleARN != "arn:aws:iam::123456789012:role/admin" {
+		t.Errorf("Unexpected entry: %+v %+v", pfi, pfi.Credential)
+	}
+	if _, err = ProfileEntryInputFromAssumeRoleJSON("assumed", []byte(`{}`)); !errors.Is(err, ErrMissingKeys) {
+		t.Errorf("Expected ErrMissingKeys, got: %v", err)
+	}
+
+	// round trip through the file
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess := assertProfiles(t, filepath.Join(dir, "credentials"), *pfi)
+	p, err := sess.GetProfile("assumed")
+	if err != nil {
+		t.Fatalf("Error getting profile: %s", err)
+	}
+	creds, err := p.Credentials("")
+	if err != nil || creds.AccessKeyID != "ASIA" || !creds.Expires.Equal(time.Date(2020, 1, 8, 14, 3, 2, 0, time.UTC)) {
+		t.Errorf("Unexpected credentials after writing: %+v %v", creds, err)
+	}
+}
+
+func TestRoleARN(t *testing.T) {
+	cases := []struct {
+		SessionARN string
+		Want       string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/admin/me", "arn:aws:iam::123456789012:role/admin"},
+		{"arn:aws-us-gov:sts::123456789012:assumed-role/admin/me", "arn:aws-us-gov:iam::123456789012:role/admin"},
+		// get-session-token and federated users can't be assumed again
+		{"arn:aws:iam::123456789012:user/me", ""},
+		{"arn:aws:sts::123456789012:federated-user/me", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if have := roleARN(c.SessionARN); have != c.Want {
+			t.Errorf("Unexpected role ARN for %s. Have: %s, Want: %s", c.SessionARN, have, c.Want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package acfmgr
+
+import (
+	"bytes"
+	"strings"
+)
+
+// tokenKind is the kind of a single line in an AWS shared
+// credentials or config file
+type tokenKind int
+
+const (
+	tokBlank        tokenKind = iota // empty or whitespace only
+	tokComment                       // starts with '#' or ';'
+	tokSection                       // '[name]' with an optional trailing comment
+	tokKeyValue                      // 'key = value'
+	tokContinuation                  // indented line directly following a key e.g., nested s3 settings
+	tokInvalid                       // anything else, kept as is
+)
+
+// token is one line of the file. raw always holds the exact bytes
+// including the line ending so untouched lines round trip.
+type token struct {
+	kind  tokenKind
+	raw   string
+	line  int    // 1 based line number in the source
+	name  string // section name for tokSection
+	key   string // for tokKeyValue
+	value string // for tokKeyValue
+}
+
+// text returns the line without its line ending
+func (t token) text() string {
+	return strings.TrimRight(t.raw, "\r\n")
+}
+
+// tokenize splits data into classified lines. Joining the raw
+// field of every token gives back data byte for byte.
+func tokenize(data []byte) (toks []token) {
+	lineNo := 0
+	inValue := false // previous line was a key or continuation
+	for len(data) > 0 {
+		lineNo++
+		var raw string
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			raw, data = string(data), nil
+		} else {
+			raw, data = string(data[:i+1]), data[i+1:]
+		}
+		t := classify(raw, lineNo, inValue)
+		switch t.kind {
+		case tokKeyValue, tokContinuation:
+			inValue = true
+		default:
+			inValue = false
+		}
+		toks = append(toks, t)
+	}
+	return toks
+}
+
+// classify works out what kind of line raw is. Indented lines are
+// only continuations when they follow a key, otherwise they're parsed
+// like any other line.
+func classify(raw string, lineNo int, inValue bool) token {
+	t := token{raw: raw, line: lineNo}
+	text := t.text()
+	trimmed := strings.TrimSpace(text)
+	var header *string
+	if strings.HasPrefix(trimmed, "[") {
+		header = sectionHeader(trimmed)
+	}
+	switch {
+	case trimmed == "":
+		t.kind = tokBlank
+	case trimmed[0] == '#' || trimmed[0] == ';':
+		t.kind = tokComment
+	case header != nil:
+		t.kind = tokSection
+		t.name = *header
+	case inValue && (text[0] == ' ' || text[0] == '\t'):
+		t.kind = tokContinuation
+	case trimmed[0] == '[':
+		t.kind = tokInvalid
+	default:
+		kv := strings.SplitN(trimmed, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			t.kind = tokInvalid
+			break
+		}
+		t.kind = tokKeyValue
+		t.key = strings.TrimSpace(kv[0])
+		t.value = strings.TrimSpace(kv[1])
+	}
+	return t
+}
+
+// sectionHeader returns the name inside a '[name]' line allowing
+// a trailing comment, or nil if the line isn't a valid header
+func sectionHeader(trimmed string) *string {
+	end := strings.IndexByte(trimmed, ']')
+	if end < 0 {
+		return nil
+	}
+	rest := strings.TrimSpace(trimmed[end+1:])
+	if rest != "" && rest[0] != '#' && rest[0] != ';' {
+		return nil
+	}
+	name := strings.TrimSpace(trimmed[1:end])
+	return &name
+}
+
+// section is a section header and every line up to the next
+// one. The first section of a document holds anything before
+// the first header and has a nil header.
+type section struct {
+	header *token
+	body   []token
+}
+
+// name returns the section name or blank for the preamble
+func (s *section) name() string {
+	if s.header == nil {
+		return ""
+	}
+	return s.header.name
+}
+
+// document is the parsed form of a whole file. Sections keep
+// their order and duplicates are kept as separate sections.
+type document struct {
+	sections []*section
+	eol      string // line ending used for new lines, matches the file
+	prefix   string // header prefix hidden from section names e.g., 'profile ' in ~/.aws/config
+}
+
+// parseDocument tokenizes data and groups the lines into sections
+func parseDocument(data []byte) *document {
+	doc := &document{eol: "\n"}
+	if i := bytes.IndexByte(data, '\n'); i > 0 && data[i-1] == '\r' {
+		doc.eol = "\r\n"
+	}
+	curr := &section{}
+	doc.sections = append(doc.sections, curr)
+	for _, t := range tokenize(data) {
+		if t.kind == tokSection {
+			header := t
+			curr = &section{header: &header}
+			doc.sections = append(doc.sections, curr)
+			continue
+		}
+		curr.body = append(curr.body, t)
+	}
+	return doc
+}
+
+// bytes renders the document back to file contents
+func (d *document) bytes() *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	for _, s := range d.sections {
+		if s.header != nil {
+			buf.WriteString(s.header.raw)
+		}
+		for _, t := range s.body {
+			buf.WriteString(t.raw)
+		}
+	}
+	return buf
+}
+
+// newLine builds a token for a line we're adding to the document
+func (d *document) newLine(text string) token {
+	return classify(text+d.eol, 0, false)
+}
+
+// usePrefix strips prefix from the section names so sections can be
+// found by profile name. Headers without the prefix keep their name.
+func (d *document) usePrefix(prefix string) {
+	d.prefix = prefix
+	for _, s := range d.sections {
+		if s.header != nil && strings.HasPrefix(s.header.name, prefix) {
+			s.header.name = strings.TrimSpace(strings.TrimPrefix(s.header.name, prefix))
+		}
+	}
+}
+
+// header builds the header line for a section name adding the
+// prefix back unless it's the default profile which never has one
+func (d *document) header(name, eol string, lineNo int) token {
+	text := "[" + name + "]"
+	if d.prefix != "" && name != "default" {
+		text = "[" + d.prefix + name + "]"
+	}
+	t := classify(text+eol, lineNo, false)
+	t.name = name
+	return t
+}
+
+// newSection builds a section from a header name and body lines
+func (d *document) newSection(name string, lines []string) *section {
+	header := d.header(name, d.eol, 0)
+	s := &section{header: &header}
+	for _, line := range lines {
+		s.body = append(s.body, d.newLine(line))
+	}
+	return s
+}
+
+// terminate makes sure the last line of the document ends with a
+// newline so anything appended after it starts on its own line
+func (d *document) terminate() {
+	last := d.sections[len(d.sections)-1]
+	if len(last.body) > 0 {
+		t := &last.body[len(last.body)-1]
+		if !strings.HasSuffix(t.raw, "\n") {
+			t.raw += d.eol
+		}
+		return
+	}
+	if last.header != nil && !strings.HasSuffix(last.header.raw, "\n") {
+		last.header.raw += d.eol
+	}
+}
@@ -0,0 +1,118 @@
+package acfmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// messyCredFile has everything the old regex based anchor
+// detection got wrong plus formatting that must survive
+const messyCredFile = "; leading comment\r\n" +
+	"\r\n" +
+	"[default]\r\n" +
+	"aws_access_key_id=AKIADEFAULT\r\n" +
+	"  # indented comment [not a section]\r\n" +
+	"aws_secret_access_key   =   secret [with brackets]\r\n" +
+	"\r\n" +
+	"  [ spaced ]  ; trailing comment\r\n" +
+	"s3 =\r\n" +
+	"    max_concurrent_requests = 20\r\n" +
+	"\tsignature_version = s3v4\r\n" +
+	"region = us-east-1\r\n" +
+	"\r\n" +
+	"[default]\r\n" +
+	"output = text\r\n" +
+	"[broken\r\n" +
+	"no equals sign here\r\n" +
+	"[last]\r\n" +
+	"key = value"
+
+func TestTokenizeRoundTrip(t *testing.T) {
+	inputs := []string{messyCredFile, baseCredFile, expectedResult, "", "\n", "[a]", "x = y\n[b]\r\n"}
+	for _, in := range inputs {
+		var out strings.Builder
+		for _, tok := range tokenize([]byte(in)) {
+			out.WriteString(tok.raw)
+		}
+		if out.String() != in {
+			t.Errorf("Tokens don't round trip. Have: %q, Want: %q", out.String(), in)
+		}
+		if got := parseDocument([]byte(in)).bytes().String(); got != in {
+			t.Errorf("Document doesn't round trip. Have: %q, Want: %q", got, in)
+		}
+	}
+}
+
+func TestTokenizeKinds(t *testing.T) {
+	doc := parseDocument([]byte(messyCredFile))
+	var names []string
+	for _, s := range doc.sections[1:] {
+		names = append(names, s.name())
+	}
+	want := "default,spaced,default,last"
+	if strings.Join(names, ",") != want {
+		t.Errorf("Unexpected sections. Have: %v, Want: %s", names, want)
+	}
+	spaced := doc.sections[2]
+	kinds := []tokenKind{tokKeyValue, tokContinuation, tokContinuation, tokKeyValue, tokBlank}
+	for i, k := range kinds {
+		if spaced.body[i].kind != k {
+			t.Errorf("Unexpected kind for %q. Have: %d, Want: %d", spaced.body[i].raw, spaced.body[i].kind, k)
+		}
+	}
+	dflt := doc.sections[3]
+	last := dflt.body[len(dflt.body)-2]
+	if last.kind != tokInvalid {
+		t.Errorf("Expected '[broken' to be invalid. Have: %d", last.kind)
+	}
+	if doc.eol != "\r\n" {
+		t.Errorf("Expected CRLF line endings to be detected")
+	}
+}
+
+func TestUntouchedSectionsRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(filename, []byte(messyCredFile), 0600)
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	tx := sess.Begin()
+	tx.Upsert(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "new", Description: "[legacy]"})
+	tx.Patch("last", map[string]string{"region": "us-west-2"})
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	got, _ := ioutil.ReadFile(filename)
+	untouched := messyCredFile[:strings.Index(messyCredFile, "[last]")]
+	if !strings.HasPrefix(string(got), untouched) {
+		t.Errorf("Untouched sections changed. Have: %q", got)
+	}
+	if !strings.Contains(string(got), "[last]\r\nkey = value\r\nregion = us-west-2\r\n[new]\r\n") {
+		t.Errorf("Patch or new section not written with file line endings. Have: %q", got)
+	}
+	// the bracketed description must not be treated as a section
+	tx = sess.Begin()
+	tx.Delete("new")
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error deleting: %s", err)
+	}
+	got, _ = ioutil.ReadFile(filename)
+	want := untouched + "[last]\r\nkey = value\r\nregion = us-west-2\r\n"
+	if string(got) != want {
+		t.Errorf("Unexpected contents after delete. Have: %q, Want: %q", got, want)
+	}
+}
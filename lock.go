@@ -0,0 +1,260 @@
+package acfmgr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// ErrLocked can be used with errors.Is to check for a LockedError.
+var ErrLocked = errors.New("credentials file is locked")
+
+// errFlockUnsupported is returned by tryFlockO when the platform
+// or filesystem can't do flock and the lock file fallback is needed
+var errFlockUnsupported = errors.New("flock not supported")
+
+// LockedError is returned when the lock on the credentials
+// file couldn't be acquired before the timeout.
+type LockedError struct {
+	Path   string        // the lock file that was held
+	Waited time.Duration // how long we waited for it
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s: gave up on %s after %s", ErrLocked, e.Path, e.Waited)
+}
+
+// Is lets errors.Is match a LockedError against ErrLocked
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// LockOptions control the advisory lock taken around the
+// reload-modify-write cycle of AssertEntries, DeleteEntries
+// and friends.
+type LockOptions struct {
+	Disabled      bool          // skip locking entirely
+	Timeout       time.Duration // how long to wait for the lock before returning a LockedError
+	RetryInterval time.Duration // how long to sleep between attempts
+	StaleAfter    time.Duration // fallback lock files older than this are assumed abandoned and removed
+}
+
+// DefaultLockOptions are used by NewCredFileSession.
+var DefaultLockOptions = LockOptions{
+	Timeout:       10 * time.Second,
+	RetryInterval: 50 * time.Millisecond,
+	StaleAfter:    2 * time.Minute,
+}
+
+// SetLockOptions changes the locking behavior of the CredFile.
+func (c *CredFile) SetLockOptions(opts LockOptions) {
+	c.lockOpts = opts
+}
+
+// fileLock is a held lock that can be released
+type fileLock struct {
+	f     *os.File // set when holding an flock
+	path  string   // the lock file on disk
+	token []byte   // what we wrote to a fallback lock file
+}
+
+// unlock removes the lock file and releases the flock. The file is
+// removed while still holding the flock so anyone waiting on the old
+// inode notices it's gone and starts over with a fresh file. A
+// fallback lock file is only removed if it still has our token so we
+// never remove one someone else took over after deciding ours was
+// stale.
+func (l *fileLock) unlock() error {
+	if l.f == nil {
+		data, err := ioutil.ReadFile(l.path)
+		if err != nil || !bytes.Equal(data, l.token) {
+			return nil
+		}
+	}
+	err := os.Remove(l.path)
+	if l.f != nil {
+		unlockFlockO(l.f)
+		cerr := l.f.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// withLock takes the lock, makes sure we're working on the latest
+// contents of the file, runs fn and then releases the lock
+func (c *CredFile) withLock(fn func() error) (err error) {
+	if c.lockOpts.Disabled {
+		return c.applyFresh(fn)
+	}
+	l, err := acquireLock(c.filename, c.lockOpts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		uerr := l.unlock()
+		if err == nil {
+			err = uerr
+		}
+	}()
+	return c.applyFresh(fn)
+}
+
+// acquireLock tries flock on '<filename>.lock' and falls back to an
+// exclusively created '<filename>.lck' file when flock isn't available
+func acquireLock(filename string, opts LockOptions) (l *fileLock, err error) {
+	start := time.Now()
+	path := filename + ".lock"
+	useFlock := true
+	for {
+		if useFlock {
+			held, err := tryFlockPath(path)
+			switch {
+			case held != nil:
+				return &fileLock{f: held, path: path}, nil
+			case errors.Is(err, errFlockUnsupported):
+				useFlock = false
+				path = filename + ".lck"
+				continue
+			case err != nil:
+				return l, err
+			}
+		} else {
+			token, err := tryLockFile(path, opts.StaleAfter)
+			if err != nil {
+				return l, err
+			}
+			if token != nil {
+				return &fileLock{path: path, token: token}, nil
+			}
+		}
+		waited := time.Since(start)
+		if waited >= opts.Timeout {
+			return l, &LockedError{Path: path, Waited: waited}
+		}
+		time.Sleep(opts.RetryInterval)
+	}
+}
+
+// tryFlockPath opens the lock file and attempts to flock it. Returns the
+// open file when the lock is held. Since lock files are removed on
+// unlock it checks the file we locked is still the one at path.
+func tryFlockPath(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := tryFlockO(f)
+	if !ok || err != nil {
+		f.Close()
+		return nil, err
+	}
+	held, err := f.Stat()
+	if err != nil {
+		unlockFlockO(f)
+		f.Close()
+		return nil, err
+	}
+	onDisk, err := os.Stat(path)
+	if err != nil || !os.SameFile(held, onDisk) {
+		// previous holder removed it out from under us
+		unlockFlockO(f)
+		f.Close()
+		return nil, nil
+	}
+	return f, nil
+}
+
+// tryLockFile attempts to exclusively create the lock file and
+// returns the unique token written to it when it's held. If the file
+// already exists and hasn't been touched in staleAfter it's moved
+// aside so the next attempt can take it.
+func tryLockFile(path string, staleAfter time.Duration) (token []byte, err error) {
+	token, id, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = f.Write(token)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		return token, nil
+	}
+	if !os.IsExist(err) {
+		return nil, err
+	}
+	f, err = os.Open(path)
+	if err != nil {
+		// holder released it between our two opens
+		return nil, nil
+	}
+	defer f.Close()
+	// the mtime and contents have to come from the same file
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if staleAfter <= 0 || time.Since(info.ModTime()) <= staleAfter {
+		return nil, nil
+	}
+	stale, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return nil, takeStaleLock(path, id, info, stale)
+}
+
+// takeStaleLock moves a stale lock file aside. Renaming is atomic so
+// only one waiter gets it, and if what we moved isn't the file we
+// judged stale (someone beat us to it and a new holder created a
+// fresh one) it's linked back into place.
+func takeStaleLock(path, id string, info os.FileInfo, stale []byte) error {
+	aside := path + ".stale-" + id
+	err := os.Rename(path, aside)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer os.Remove(aside)
+	movedInfo, err := os.Stat(aside)
+	if err != nil {
+		return err
+	}
+	moved, err := ioutil.ReadFile(aside)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, movedInfo) || !bytes.Equal(moved, stale) {
+		err = os.Link(aside, path)
+		if os.IsExist(err) {
+			err = nil
+		}
+	}
+	return err
+}
+
+// lockToken returns a line that's unique to this attempt
+// and the random id in it
+func lockToken() (token []byte, id string, err error) {
+	b := make([]byte, 8)
+	_, err = rand.Read(b)
+	if err != nil {
+		return nil, id, err
+	}
+	id = fmt.Sprintf("%x", b)
+	token = []byte(fmt.Sprintf("%d %s %s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339), id))
+	return token, id, err
+}
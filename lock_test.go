@@ -0,0 +1,181 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockedError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	// someone else is holding the lock
+	held, err := acquireLock(filename, DefaultLockOptions)
+	if err != nil {
+		t.Fatalf("Error taking lock: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	sess.SetLockOptions(LockOptions{Timeout: 100 * time.Millisecond, RetryInterval: 10 * time.Millisecond})
+	pfi := ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "acfmgrtest"}
+	err = sess.NewEntry(&pfi)
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	var lerr *LockedError
+	if !errors.Is(err, ErrLocked) || !errors.As(err, &lerr) {
+		t.Fatalf("Expected LockedError, got: %v", err)
+	}
+	err = held.unlock()
+	if err != nil {
+		t.Fatalf("Error releasing lock: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Error asserting entries after unlock: %s", err)
+	}
+	if _, err := os.Stat(filename + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Lock file left behind: %v", err)
+	}
+}
+
+func TestReloadPicksUpOtherWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	first, _ := NewCredFileSession(filename)
+	second, _ := NewCredFileSession(filename)
+	first.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "first"})
+	second.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "second"})
+	if err := first.AssertEntries(); err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	if err := second.AssertEntries(); err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	for _, name := range []string{"first", "second"} {
+		if _, err := second.GetProfile(name); err != nil {
+			t.Errorf("Profile %s was dropped: %s", name, err)
+		}
+	}
+}
+
+func TestStaleLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.lck")
+	err = ioutil.WriteFile(path, []byte("12345\n"), 0600)
+	if err != nil {
+		t.Fatalf("Error writing lock file: %s", err)
+	}
+	token, err := tryLockFile(path, time.Minute)
+	if token != nil || err != nil {
+		t.Fatalf("Expected fresh lock file to be respected. Have: %q, %v", token, err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path, old, old)
+	token, _ = tryLockFile(path, time.Minute)
+	if token != nil {
+		t.Fatalf("Stale lock should be moved aside first, not taken in the same attempt")
+	}
+	token, err = tryLockFile(path, time.Minute)
+	if token == nil || err != nil {
+		t.Fatalf("Expected stale lock to be taken over. Have: %q, %v", token, err)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the lock file in dir, have %d entries", len(entries))
+	}
+
+	// the original holder comes back after we took over
+	stale := &fileLock{path: path, token: []byte("12345\n")}
+	err = stale.unlock()
+	if err != nil {
+		t.Errorf("Error unlocking stale lock: %s", err)
+	}
+	got, _ := ioutil.ReadFile(path)
+	if string(got) != string(token) {
+		t.Errorf("Stale holder removed our lock. Have: %q, Want: %q", got, token)
+	}
+	l := &fileLock{path: path, token: token}
+	err = l.unlock()
+	if err != nil {
+		t.Errorf("Error unlocking: %s", err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected lock file to be removed, got: %v", err)
+	}
+}
+
+func TestTakeStaleLockPutsBackFreshLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.lck")
+	token := []byte("12345 token\n")
+	err = ioutil.WriteFile(path, token, 0600)
+	if err != nil {
+		t.Fatalf("Error writing lock file: %s", err)
+	}
+	staleInfo, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another waiter takes over and a new holder creates a fresh lock
+	// between us judging it stale and renaming it. Even with the same
+	// contents it's a different file so it has to be put back.
+	err = os.Rename(path, filepath.Join(dir, "taken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, token, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = takeStaleLock(path, "abc", staleInfo, token)
+	if err != nil {
+		t.Fatalf("Error taking stale lock: %s", err)
+	}
+	freshInfo, err := os.Stat(path)
+	if err != nil || os.SameFile(staleInfo, freshInfo) {
+		t.Errorf("Fresh lock wasn't put back: %v", err)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected only the lock file and the taken one in dir, have %d entries", len(entries))
+	}
+	// the file we judged stale is still there so it goes
+	err = takeStaleLock(path, "abc", freshInfo, token)
+	if err != nil {
+		t.Fatalf("Error taking stale lock: %s", err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected stale lock to be moved aside, got: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package acfmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/user"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrInvalidManifest is returned when a manifest
+// can't be parsed or has bad entries.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// Manifest is a list of roles to assume and the profiles to
+// write them to. It can be written as JSON or YAML:
+//
+//  session_name: oncall
+//  profiles:
+//    - profile: devaccount
+//      role_arn: arn:aws:iam::123456789012:role/aj/d-admin
+//      region: us-east-1
+//      output: json
+//      description: gossamer-legacy
+type Manifest struct {
+	SessionName     string          `json:"session_name" yaml:"session_name"`         // OPTIONAL: RoleSessionName for every entry, defaults to DefaultSessionName
+	DurationSeconds int             `json:"duration_seconds" yaml:"duration_seconds"` // OPTIONAL: how long the credentials last, defaults to the role's setting
+	Profiles        []ManifestEntry `json:"profiles" yaml:"profiles"`                 // MANDATORY: the profiles to write
+}
+
+// ManifestEntry is a single profile in a Manifest.
+type ManifestEntry struct {
+	Profile     string `json:"profile" yaml:"profile"`         // MANDATORY: name of the profile to write
+	RoleARN     string `json:"role_arn" yaml:"role_arn"`       // MANDATORY: the role to assume
+	Region      string `json:"region" yaml:"region"`           // OPTIONAL: region to include in the profile entry
+	Output      string `json:"output" yaml:"output"`           // OPTIONAL: output format to include in the profile entry
+	Description string `json:"description" yaml:"description"` // OPTIONAL: a description to give this entry
+	ExternalID  string `json:"external_id" yaml:"external_id"` // OPTIONAL: the external ID the role's trust policy expects
+}
+
+// DefaultSessionName is the RoleSessionName used for
+// manifests that don't set one.
+const DefaultSessionName = "acfmgr"
+
+// LoadManifest reads and parses a JSON or YAML manifest file.
+func LoadManifest(filename string) (m *Manifest, err error) {
+	usr, err := user.Current()
+	if err != nil {
+		return m, err
+	}
+	filename, err = expandPath(filename, usr)
+	if err != nil {
+		return m, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return m, err
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest parses a JSON or YAML manifest and checks that every
+// entry has a profile and role and that no profile is listed twice.
+func ParseManifest(data []byte) (m *Manifest, err error) {
+	m = &Manifest{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		// JSON is mostly YAML but tabs aren't so don't risk it
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(m)
+	} else {
+		err = yaml.UnmarshalStrict(data, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidManifest, err)
+	}
+	return m, m.validate()
+}
+
+// validate checks the entries. Profiles that only differ by case
+// count as duplicates since they'd trip the default NamePolicy.
+func (m *Manifest) validate() error {
+	if len(m.Profiles) == 0 {
+		return fmt.Errorf("%w: no profiles", ErrInvalidManifest)
+	}
+	seen := make(map[string]bool)
+	for i, e := range m.Profiles {
+		if e.Profile == "" || e.RoleARN == "" {
+			return fmt.Errorf("%w: entry %d needs profile and role_arn", ErrInvalidManifest, i+1)
+		}
+		key := strings.ToLower(cleanProfileName(e.Profile))
+		if seen[key] {
+			return fmt.Errorf("%w: profile %s is listed more than once", ErrInvalidManifest, e.Profile)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testManifestYAML = `session_name: oncall
+duration_seconds: 3600
+profiles:
+  - profile: dev
+    role_arn: arn:aws:iam::123456789012:role/aj/d-admin
+    region: us-east-1
+    output: json
+    description: gossamer-legacy
+  - profile: prod
+    role_arn: arn:aws:iam::210987654321:role/aj/d-readonly
+    external_id: xyz
+`
+
+const testManifestJSON = `{
+	"session_name": "oncall",
+	"duration_seconds": 3600,
+	"profiles": [
+		{"profile": "dev", "role_arn": "arn:aws:iam::123456789012:role/aj/d-admin", "region": "us-east-1", "output": "json", "description": "gossamer-legacy"},
+		{"profile": "prod", "role_arn": "arn:aws:iam::210987654321:role/aj/d-readonly", "external_id": "xyz"}
+	]
+}`
+
+func TestParseManifest(t *testing.T) {
+	for _, data := range []string{testManifestYAML, testManifestJSON} {
+		m, err := ParseManifest([]byte(data))
+		if err != nil {
+			t.Fatalf("Error parsing manifest: %s", err)
+		}
+		if m.SessionName != "oncall" || m.DurationSeconds != 3600 || len(m.Profiles) != 2 {
+			t.Fatalf("Unexpected manifest: %+v", m)
+		}
+		want := ManifestEntry{Profile: "dev", RoleARN: "arn:aws:iam::123456789012:role/aj/d-admin", Region: "us-east-1", Output: "json", Description: "gossamer-legacy"}
+		if m.Profiles[0] != want || m.Profiles[1].ExternalID != "xyz" {
+			t.Errorf("Unexpected entries. Have: %+v, Want first: %+v", m.Profiles, want)
+		}
+	}
+	bad := []string{
+		"profiles: []",
+		"profiles:\n  - profile: dev\n",
+		"profiles:\n  - profile: dev\n    role_arn: a\n  - profile: DEV\n    role_arn: b\n",
+		"profiles:\n  - profile: dev\n    role_arn: a\n    typo: x\n",
+		`{"profiles": [{"profile": "dev", "role_arn": "a", "typo": "x"}]}`,
+	}
+	for _, data := range bad {
+		if _, err := ParseManifest([]byte(data)); !errors.Is(err, ErrInvalidManifest) {
+			t.Errorf("Expected ErrInvalidManifest for %q, got: %v", data, err)
+		}
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "manifest.yaml")
+	err = ioutil.WriteFile(filename, []byte(testManifestYAML), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(filename)
+	if err != nil || len(m.Profiles) != 2 {
+		t.Errorf("Unexpected result loading manifest: %v %v", m, err)
+	}
+}
@@ -0,0 +1,100 @@
+package acfmgr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// header prefixes written by the default credFileTemplate
+const (
+	defaultExpiresToken = "# EXPIRES@"
+	assumedRolePrefix   = "# ASSUMED ROLE:"
+	instanceRolePrefix  = "# ASSUMED FROM INSTANCE ROLE:"
+	generatedPrefix     = "# GENERATED:"
+	descriptionPrefix   = "# DESCRIPTION:"
+)
+
+// headerTimeFormat is the layout produced by time.Time.String()
+// which is what the template uses for GENERATED and EXPIRES@
+const headerTimeFormat = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// ErrNotManaged is returned when metadata is requested
+// for a profile that was not written by acfmgr.
+var ErrNotManaged = errors.New("profile is not an acfmgr managed section")
+
+// ManagedEntryMetadata holds the values recovered from the
+// comment header of an acfmgr managed profile section.
+type ManagedEntryMetadata struct {
+	AssumeRoleARN   string    // empty if the header recorded 'NA'
+	InstanceRoleARN string    // empty if the header recorded 'NA'
+	Generated       time.Time // zero if not found
+	Expires         time.Time // zero if not found
+	Description     string    // empty if the entry had no description
+}
+
+// HasExpiry reports whether an expiry time was found in the header.
+func (m *ManagedEntryMetadata) HasExpiry() bool {
+	return !m.Expires.IsZero()
+}
+
+// Metadata parses the managed section header of the profile. The
+// expiresToken should match the ExpiresToken given in the
+// ProfileEntryInput when the entry was written. If it's blank
+// the package default is used.
+func (p *Profile) Metadata(expiresToken string) (md *ManagedEntryMetadata, err error) {
+	if !p.Managed {
+		return md, fmt.Errorf("%w: %s", ErrNotManaged, p.Name)
+	}
+	return parseManagedMetadata(p.lines, expiresToken)
+}
+
+// parseManagedMetadata scans the raw lines of a section for the
+// header fields written by the default template
+func parseManagedMetadata(lines []string, expiresToken string) (md *ManagedEntryMetadata, err error) {
+	if expiresToken == "" {
+		expiresToken = defaultExpiresToken
+	}
+	md = &ManagedEntryMetadata{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, instanceRolePrefix):
+			md.InstanceRoleARN = naToBlank(strings.TrimPrefix(line, instanceRolePrefix))
+		case strings.HasPrefix(line, assumedRolePrefix):
+			md.AssumeRoleARN = naToBlank(strings.TrimPrefix(line, assumedRolePrefix))
+		case strings.HasPrefix(line, descriptionPrefix):
+			md.Description = strings.TrimSpace(strings.TrimPrefix(line, descriptionPrefix))
+		case strings.HasPrefix(line, generatedPrefix):
+			md.Generated, err = parseHeaderTime(strings.TrimPrefix(line, generatedPrefix))
+			if err != nil {
+				return md, fmt.Errorf("parsing generated time: %w", err)
+			}
+		case strings.HasPrefix(line, expiresToken):
+			md.Expires, err = parseHeaderTime(strings.TrimPrefix(line, expiresToken))
+			if err != nil {
+				return md, fmt.Errorf("parsing expiry time: %w", err)
+			}
+		}
+	}
+	return md, err
+}
+
+// parseHeaderTime parses a time written with time.Time.String()
+// dropping any monotonic clock suffix e.g., 'm=+0.001'
+func parseHeaderTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	return time.Parse(headerTimeFormat, s)
+}
+
+func naToBlank(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "NA" {
+		return ""
+	}
+	return s
+}
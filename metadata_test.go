@@ -0,0 +1,94 @@
+package acfmgr
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestManagedMetadata(t *testing.T) {
+	filename := "./acfmgr_credfile_test_meta.txt"
+	err := writeBaseFile(filename)
+	if err != nil {
+		t.Errorf("Error making basefile: %s", err)
+	}
+	defer os.Remove(filename)
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	pfi := ProfileEntryInput{
+		Credential:       getFakeCreds(),
+		ProfileEntryName: "acfmgrtest",
+		AssumeRoleARN:    "arn:aws:iam::123456789012:role/aj/d-admin",
+		Description:      "gossamer-legacy",
+	}
+	err = sess.NewEntry(&pfi)
+	if err != nil {
+		t.Errorf("Error adding entry: %s", err)
+	}
+	// custom token that doesn't even look like a comment
+	custom := ProfileEntryInput{
+		Credential:       getFakeCreds(),
+		ProfileEntryName: "customtoken",
+		ExpiresToken:     "#~expiry~",
+	}
+	err = sess.NewEntry(&custom)
+	if err != nil {
+		t.Errorf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Error asserting entries: %s", err)
+	}
+	p, err := sess.GetProfile("acfmgrtest")
+	if err != nil {
+		t.Fatalf("Error getting profile: %s", err)
+	}
+	md, err := p.Metadata("")
+	if err != nil {
+		t.Fatalf("Error parsing metadata: %s", err)
+	}
+	if md.AssumeRoleARN != pfi.AssumeRoleARN {
+		t.Errorf("Unexpected AssumeRoleARN. Have: '%s'", md.AssumeRoleARN)
+	}
+	if md.InstanceRoleARN != "" {
+		t.Errorf("Expected blank InstanceRoleARN for NA. Have: '%s'", md.InstanceRoleARN)
+	}
+	if md.Description != pfi.Description {
+		t.Errorf("Unexpected Description. Have: '%s'", md.Description)
+	}
+	if !md.Expires.Equal(getFakeCreds().Expires) {
+		t.Errorf("Unexpected Expires. Have: '%s', Want: '%s'", md.Expires, getFakeCreds().Expires)
+	}
+	if md.Generated.IsZero() {
+		t.Errorf("Generated time was not parsed")
+	}
+	p, err = sess.GetProfile("customtoken")
+	if err != nil {
+		t.Fatalf("Error getting profile: %s", err)
+	}
+	md, err = p.Metadata(custom.ExpiresToken)
+	if err != nil {
+		t.Fatalf("Error parsing metadata: %s", err)
+	}
+	if !md.Expires.Equal(getFakeCreds().Expires) {
+		t.Errorf("Unexpected Expires with custom token. Have: '%s'", md.Expires)
+	}
+	p, _ = sess.GetProfile("testing")
+	_, err = p.Metadata("")
+	if !errors.Is(err, ErrNotManaged) {
+		t.Errorf("Expected ErrNotManaged, got: %v", err)
+	}
+}
+
+func TestParseManagedMetadata(t *testing.T) {
+	lines := []string{managedMarker, "EXP 2020-01-08 14:03:02 +0000 UTC"}
+	md, err := parseManagedMetadata(lines, "EXP")
+	if err != nil {
+		t.Fatalf("Error parsing metadata: %s", err)
+	}
+	if !md.HasExpiry() || !md.Expires.Equal(getFakeCreds().Expires) {
+		t.Errorf("Unexpected Expires. Have: '%s'", md.Expires)
+	}
+}
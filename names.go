@@ -0,0 +1,159 @@
+package acfmgr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// reasons a NameError can wrap, check them with errors.Is
+var (
+	ErrNameBlank     = errors.New("name is blank")
+	ErrNameInvalid   = errors.New("name contains characters that aren't allowed")
+	ErrNameReserved  = errors.New("name is reserved")
+	ErrNameCollision = errors.New("name differs only by case from an existing profile")
+)
+
+// DefaultReservedNames are the names that need AllowReserved
+// in a ProfileNamePolicy before they can be written.
+var DefaultReservedNames = []string{"default"}
+
+// NameError is returned when a profile name is rejected
+// by a NamePolicy.
+type NameError struct {
+	Name     string // the name as it was given
+	Reason   error  // one of the ErrName* errors
+	Conflict string // the existing profile for ErrNameCollision
+}
+
+func (e *NameError) Error() string {
+	if e.Conflict != "" {
+		return fmt.Sprintf("invalid profile name %q: %s (%q)", e.Name, e.Reason, e.Conflict)
+	}
+	return fmt.Sprintf("invalid profile name %q: %s", e.Name, e.Reason)
+}
+
+// Unwrap lets errors.Is match the Reason
+func (e *NameError) Unwrap() error {
+	return e.Reason
+}
+
+// NamePolicy checks and cleans up profile names before they're
+// written. existing holds the names of the profiles already in
+// the file and queued in the session. It returns the name to use
+// without brackets or an error, ideally a *NameError.
+type NamePolicy interface {
+	CheckName(name string, existing []string) (string, error)
+}
+
+// ProfileNamePolicy is the default NamePolicy. Out of the box it
+// removes brackets, converts spaces to dashes, rejects blank names,
+// control characters, reserved names and names that differ only by
+// case from an existing profile.
+type ProfileNamePolicy struct {
+	Strict              bool     // reject names that need cleaning up or have characters outside [A-Za-z0-9._@+-] instead of rewriting them
+	AllowReserved       bool     // allow names in Reserved e.g., 'default'
+	Reserved            []string // defaults to DefaultReservedNames
+	AllowCaseCollisions bool     // allow names that differ only by case from an existing profile
+}
+
+// CheckName implements NamePolicy.
+func (p *ProfileNamePolicy) CheckName(name string, existing []string) (string, error) {
+	cleaned := cleanProfileName(strings.TrimSpace(name))
+	if p.Strict {
+		cleaned = name
+	}
+	if cleaned == "" {
+		return "", &NameError{Name: name, Reason: ErrNameBlank}
+	}
+	for i, r := range cleaned {
+		if unicode.IsControl(r) {
+			return "", &NameError{Name: name, Reason: ErrNameInvalid}
+		}
+		if p.Strict && !strictNameRune(r, i) {
+			return "", &NameError{Name: name, Reason: ErrNameInvalid}
+		}
+	}
+	reserved := p.Reserved
+	if reserved == nil {
+		reserved = DefaultReservedNames
+	}
+	if !p.AllowReserved {
+		for _, r := range reserved {
+			if strings.EqualFold(cleaned, r) {
+				return "", &NameError{Name: name, Reason: ErrNameReserved}
+			}
+		}
+	}
+	if !p.AllowCaseCollisions {
+		for _, e := range existing {
+			if e != cleaned && strings.EqualFold(e, cleaned) {
+				return "", &NameError{Name: name, Reason: ErrNameCollision, Conflict: e}
+			}
+		}
+	}
+	return cleaned, nil
+}
+
+// strictNameRune reports whether r is safe in both INI headers and
+// shell arguments. Names can't start with '-' so they aren't taken
+// for flags.
+func strictNameRune(r rune, pos int) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r == '-':
+		return pos > 0
+	case r == '.', r == '_', r == '@', r == '+':
+		return true
+	}
+	return false
+}
+
+// SetNamePolicy changes the policy used to check profile
+// names given to NewEntry and transactions.
+func (c *CredFile) SetNamePolicy(p NamePolicy) {
+	c.names = p
+}
+
+// checkName runs name through the NamePolicy using the
+// profiles in the file and the queue as existing names
+func (c *CredFile) checkName(name string) (string, error) {
+	return c.names.CheckName(name, c.known.list)
+}
+
+// nameSet is the profile names in the file and the queue so
+// checkName doesn't have to parse the file every time
+type nameSet struct {
+	list []string
+	has  map[string]bool
+}
+
+func (s *nameSet) add(name string) {
+	if s.has == nil {
+		s.has = make(map[string]bool)
+	}
+	if !s.has[name] {
+		s.has[name] = true
+		s.list = append(s.list, name)
+	}
+}
+
+// rememberNames rebuilds the set of known names from the
+// buffer and the queue, call it whenever the buffer changes
+func (c *CredFile) rememberNames() {
+	c.known = nameSet{}
+	for _, p := range c.ListProfiles() {
+		c.known.add(p.Name)
+	}
+	for _, e := range c.ents {
+		c.known.add(sectionName(e.name))
+	}
+}
+
+// queue adds an entry for AssertEntries and DeleteEntries
+func (c *CredFile) queue(e *credEntry) {
+	c.ents = append(c.ents, e)
+	c.known.add(sectionName(e.name))
+}
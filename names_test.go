@@ -0,0 +1,112 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileNamePolicy(t *testing.T) {
+	existing := []string{"devaccount", "Prod"}
+	cases := []struct {
+		policy ProfileNamePolicy
+		name   string
+		want   string
+		err    error
+	}{
+		{ProfileNamePolicy{}, "[dev account]", "dev-account", nil},
+		{ProfileNamePolicy{}, "devaccount", "devaccount", nil},
+		{ProfileNamePolicy{}, "", "", ErrNameBlank},
+		{ProfileNamePolicy{}, "[]", "", ErrNameBlank},
+		{ProfileNamePolicy{}, "   ", "", ErrNameBlank},
+		{ProfileNamePolicy{}, "bad\tname", "", ErrNameInvalid},
+		{ProfileNamePolicy{}, "default", "", ErrNameReserved},
+		{ProfileNamePolicy{}, "DEFAULT", "", ErrNameReserved},
+		{ProfileNamePolicy{AllowReserved: true}, "default", "default", nil},
+		{ProfileNamePolicy{Reserved: []string{"admin"}}, "default", "default", nil},
+		{ProfileNamePolicy{Reserved: []string{"admin"}}, "admin", "", ErrNameReserved},
+		{ProfileNamePolicy{}, "prod", "", ErrNameCollision},
+		{ProfileNamePolicy{AllowCaseCollisions: true}, "prod", "prod", nil},
+		{ProfileNamePolicy{Strict: true}, "dev.account_1@corp+x", "dev.account_1@corp+x", nil},
+		{ProfileNamePolicy{Strict: true}, "dev account", "", ErrNameInvalid},
+		{ProfileNamePolicy{Strict: true}, "[dev]", "", ErrNameInvalid},
+		{ProfileNamePolicy{Strict: true}, "-dev", "", ErrNameInvalid},
+		{ProfileNamePolicy{Strict: true}, "dev;rm", "", ErrNameInvalid},
+	}
+	for _, tc := range cases {
+		have, err := tc.policy.CheckName(tc.name, existing)
+		if !errors.Is(err, tc.err) || (err == nil) != (tc.err == nil) {
+			t.Errorf("Unexpected error for %q. Have: %v, Want: %v", tc.name, err, tc.err)
+		}
+		if have != tc.want {
+			t.Errorf("Unexpected name for %q. Have: %q, Want: %q", tc.name, have, tc.want)
+		}
+		var nameErr *NameError
+		if err != nil && (!errors.As(err, &nameErr) || nameErr.Name != tc.name) {
+			t.Errorf("Expected NameError naming %q, got: %v", tc.name, err)
+		}
+	}
+	_, err := (&ProfileNamePolicy{}).CheckName("prod", existing)
+	var nameErr *NameError
+	if !errors.As(err, &nameErr) || nameErr.Conflict != "Prod" {
+		t.Errorf("Expected collision with Prod, got: %v", err)
+	}
+}
+
+func TestNewEntryNamePolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess := assertProfiles(t, filepath.Join(dir, "credentials"),
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "devaccount"},
+	)
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: ""})
+	if !errors.Is(err, ErrNameBlank) {
+		t.Errorf("Expected ErrNameBlank, got: %v", err)
+	}
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "DevAccount"})
+	if !errors.Is(err, ErrNameCollision) {
+		t.Errorf("Expected ErrNameCollision, got: %v", err)
+	}
+	// queued entries count as existing too
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "queued"})
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "QUEUED"})
+	if !errors.Is(err, ErrNameCollision) {
+		t.Errorf("Expected ErrNameCollision for queued entry, got: %v", err)
+	}
+	tx := sess.Begin()
+	tx.Rename("devaccount", "default")
+	if err = tx.Commit(); !errors.Is(err, ErrNameReserved) {
+		t.Errorf("Expected ErrNameReserved from rename, got: %v", err)
+	}
+	sess.SetNamePolicy(&ProfileNamePolicy{AllowReserved: true})
+	tx = sess.Begin()
+	tx.Rename("devaccount", "default")
+	if err = tx.Commit(); err != nil {
+		t.Errorf("Error renaming to default with AllowReserved: %s", err)
+	}
+	// known names follow the file when it's reloaded
+	sess.SetNamePolicy(&ProfileNamePolicy{})
+	err = ioutil.WriteFile(sess.filename, []byte("[External]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sess.checkName("external"); !errors.Is(err, ErrNameCollision) {
+		t.Errorf("Expected ErrNameCollision after reload, got: %v", err)
+	}
+	sess.SetNamePolicy(&ProfileNamePolicy{Strict: true})
+	if err = sess.Begin().Upsert(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "has space"}); !errors.Is(err, ErrNameInvalid) {
+		t.Errorf("Expected ErrNameInvalid in strict mode, got: %v", err)
+	}
+}
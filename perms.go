@@ -0,0 +1,117 @@
+package acfmgr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrInsecurePermissions is returned when writing to a file that is
+// readable by group or world with the PermissionsEnforce mode.
+var ErrInsecurePermissions = errors.New("credentials file is readable by other users")
+
+// ErrForeignOwner is returned when writing to a file owned by a
+// different user without AllowForeignOwner set.
+var ErrForeignOwner = errors.New("credentials file is owned by a different user")
+
+// currentUID is a variable so tests can pretend to be someone else
+var currentUID = os.Geteuid
+
+// PermissionMode decides what happens when the credentials file
+// is readable by group or world.
+type PermissionMode int
+
+const (
+	// PermissionsReport leaves the file alone. Problems can still be
+	// found with CredFile.CheckPermissions. This is the default.
+	PermissionsReport PermissionMode = iota
+	// PermissionsTighten removes group and world access from the
+	// file before every write.
+	PermissionsTighten
+	// PermissionsEnforce refuses to write with ErrInsecurePermissions.
+	PermissionsEnforce
+)
+
+// PermissionOptions control how the CredFile treats the
+// permissions and ownership of an existing file.
+type PermissionOptions struct {
+	Mode              PermissionMode
+	AllowForeignOwner bool // write even when the file is owned by another user
+}
+
+// PermissionIssue is a problem found by CheckPermissions.
+type PermissionIssue struct {
+	Path    string
+	Mode    os.FileMode
+	Problem string
+}
+
+func (p PermissionIssue) String() string {
+	return fmt.Sprintf("%s (%s): %s", p.Path, p.Mode.Perm(), p.Problem)
+}
+
+// SetPermissionOptions changes how the CredFile treats the
+// permissions and ownership of the file.
+func (c *CredFile) SetPermissionOptions(opts PermissionOptions) {
+	c.permOpts = opts
+}
+
+// CheckPermissions reports if the credentials file is readable or
+// writable by group or world, if the directory it's in is writable
+// by group or world, or if it's owned by a different user. Always
+// returns nothing on windows where access is controlled by ACLs.
+func (c *CredFile) CheckPermissions() (issues []PermissionIssue, err error) {
+	if runtime.GOOS == "windows" {
+		return issues, err
+	}
+	info, err := os.Stat(c.filename)
+	if err != nil {
+		return issues, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		issues = append(issues, PermissionIssue{Path: c.filename, Mode: info.Mode(), Problem: "accessible by group or world, should be 0600"})
+	}
+	if uid, ok := fileOwnerO(info); ok && uid != currentUID() {
+		issues = append(issues, PermissionIssue{Path: c.filename, Mode: info.Mode(), Problem: fmt.Sprintf("owned by uid %d", uid)})
+	}
+	dir := filepath.Dir(c.filename)
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		return issues, err
+	}
+	if dirInfo.Mode().Perm()&0022 != 0 && dirInfo.Mode()&os.ModeSticky == 0 {
+		issues = append(issues, PermissionIssue{Path: dir, Mode: dirInfo.Mode(), Problem: "directory writable by group or world"})
+	}
+	return issues, nil
+}
+
+// checkPermissionsBeforeWrite applies the PermissionOptions
+// to the file about to be overwritten
+func (c *CredFile) checkPermissionsBeforeWrite() error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := os.Stat(c.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if uid, ok := fileOwnerO(info); ok && uid != currentUID() && !c.permOpts.AllowForeignOwner {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrForeignOwner, c.filename, uid)
+	}
+	perm := info.Mode().Perm()
+	if perm&0077 == 0 {
+		return nil
+	}
+	switch c.permOpts.Mode {
+	case PermissionsTighten:
+		return os.Chmod(c.filename, perm&^0077)
+	case PermissionsEnforce:
+		return fmt.Errorf("%w: %s has mode %s", ErrInsecurePermissions, c.filename, perm)
+	}
+	return nil
+}
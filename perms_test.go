@@ -0,0 +1,134 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSecureCreate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't meaningful on windows")
+	}
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, ".aws", "credentials")
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	info, _ := os.Stat(filename)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("New file should be 0600. Have: %s", info.Mode().Perm())
+	}
+	info, _ = os.Stat(filepath.Dir(filename))
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("New directory should be 0700. Have: %s", info.Mode().Perm())
+	}
+	issues, err := sess.CheckPermissions()
+	if err != nil || len(issues) != 0 {
+		t.Errorf("Expected no issues. Have: %v, %v", issues, err)
+	}
+}
+
+func TestPermissionModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't meaningful on windows")
+	}
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	cases := []struct {
+		Mode        PermissionMode
+		ExpectedErr error
+		WantPerm    os.FileMode
+	}{
+		{Mode: PermissionsReport, WantPerm: 0644},
+		{Mode: PermissionsTighten, WantPerm: 0600},
+		{Mode: PermissionsEnforce, ExpectedErr: ErrInsecurePermissions, WantPerm: 0644},
+	}
+	for _, c := range cases {
+		err = writeBaseFile(filename)
+		if err != nil {
+			t.Fatalf("Error making basefile: %s", err)
+		}
+		os.Chmod(filename, 0644)
+		sess, err := NewCredFileSession(filename)
+		if err != nil {
+			t.Fatalf("Error making credfile session: %s", err)
+		}
+		issues, err := sess.CheckPermissions()
+		if err != nil || len(issues) != 1 {
+			t.Errorf("Expected one issue. Have: %v, %v", issues, err)
+		}
+		sess.SetPermissionOptions(PermissionOptions{Mode: c.Mode})
+		sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "acfmgrtest"})
+		err = sess.AssertEntries()
+		if !errors.Is(err, c.ExpectedErr) {
+			t.Errorf("Unexpected error for mode %d. Have: %v, Want: %v", c.Mode, err, c.ExpectedErr)
+		}
+		info, _ := os.Stat(filename)
+		if info.Mode().Perm() != c.WantPerm {
+			t.Errorf("Unexpected mode for mode %d. Have: %s, Want: %s", c.Mode, info.Mode().Perm(), c.WantPerm)
+		}
+	}
+}
+
+func TestForeignOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ownership isn't checked on windows")
+	}
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	orig := currentUID
+	currentUID = func() int { return orig() + 1 }
+	defer func() { currentUID = orig }()
+	sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "acfmgrtest"})
+	err = sess.AssertEntries()
+	if !errors.Is(err, ErrForeignOwner) {
+		t.Errorf("Expected ErrForeignOwner, got: %v", err)
+	}
+	sess.SetPermissionOptions(PermissionOptions{AllowForeignOwner: true})
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Expected write to be allowed, got: %v", err)
+	}
+	// without root the owner can't be kept but the write still goes ahead
+	origCopy := copyOwner
+	copyOwner = func(path string, info os.FileInfo) error {
+		return &os.PathError{Op: "chown", Path: path, Err: os.ErrPermission}
+	}
+	defer func() { copyOwner = origCopy }()
+	sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "another"})
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Expected write to be allowed without keeping the owner, got: %v", err)
+	}
+	sess.SetPermissionOptions(PermissionOptions{})
+	currentUID = orig
+	sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "third"})
+	if err = sess.AssertEntries(); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Expected the chown error without AllowForeignOwner, got: %v", err)
+	}
+}
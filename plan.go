@@ -0,0 +1,130 @@
+package acfmgr
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+// PlanMode picks which queued operation Plan describes.
+type PlanMode int
+
+const (
+	// PlanAssert describes what AssertEntries would do
+	PlanAssert PlanMode = iota
+	// PlanDelete describes what DeleteEntries would do
+	PlanDelete
+)
+
+// PlanAction is the kind of change a PlanChange describes.
+type PlanAction string
+
+// actions that can show up in a Plan
+const (
+	ActionCreate  PlanAction = "create"
+	ActionReplace PlanAction = "replace"
+	ActionRemove  PlanAction = "remove"
+	ActionRename  PlanAction = "rename"
+	ActionPatch   PlanAction = "patch"
+)
+
+// PlanChange is a single section level change in a Plan.
+type PlanChange struct {
+	Action  PlanAction
+	Profile string // profile name without brackets
+	NewName string // only set for ActionRename
+}
+
+// Plan describes what a write would do to the credentials file
+// without touching the disk.
+type Plan struct {
+	Changes []PlanChange
+	Diff    string // unified diff of the file with secrets masked, empty if nothing changes
+}
+
+// String renders the plan as a list of changes followed by the diff.
+func (p *Plan) String() string {
+	out := new(bytes.Buffer)
+	if len(p.Changes) == 0 {
+		out.WriteString("no changes\n")
+	}
+	for _, c := range p.Changes {
+		if c.Action == ActionRename {
+			fmt.Fprintf(out, "%-8s %s -> %s\n", c.Action, c.Profile, c.NewName)
+			continue
+		}
+		fmt.Fprintf(out, "%-8s %s\n", c.Action, c.Profile)
+	}
+	if p.Diff != "" {
+		out.WriteString("\n")
+		out.WriteString(p.Diff)
+	}
+	return out.String()
+}
+
+// Plan returns what AssertEntries or DeleteEntries would do to the
+// contents loaded in this session without writing anything.
+func (c *CredFile) Plan(mode PlanMode) (*Plan, error) {
+	return c.planOps(entryOps(mode == PlanAssert, c.ents))
+}
+
+// Plan returns what Commit would do without writing anything.
+func (t *Tx) Plan() (*Plan, error) {
+	if t.done {
+		return nil, ErrTxDone
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.c.planOps(t.ops)
+}
+
+func (c *CredFile) planOps(ops []txOp) (plan *Plan, err error) {
+	after, err := c.renderOps(ops)
+	if err != nil {
+		return plan, err
+	}
+	plan = &Plan{}
+	present := make(map[string]bool)
+	for _, s := range c.document().sections {
+		if s.header != nil {
+			present[s.name()] = true
+		}
+	}
+	for _, op := range ops {
+		header := op.profile()
+		change := PlanChange{Profile: header}
+		switch op.kind {
+		case opUpsert:
+			change.Action = ActionCreate
+			if present[header] {
+				change.Action = ActionReplace
+			}
+			present[header] = true
+		case opDelete:
+			if !present[header] {
+				continue
+			}
+			change.Action = ActionRemove
+			present[header] = false
+		case opRename:
+			change.Action = ActionRename
+			change.NewName = op.newName
+			present[header] = false
+			present[op.newName] = true
+		case opPatch:
+			change.Action = ActionPatch
+		case opRefresh:
+			change.Action = ActionReplace
+		case opMerge:
+			change.Action = ActionCreate
+			if present[header] {
+				change.Action = ActionPatch
+			}
+			present[header] = true
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	plan.Diff = unifiedDiff(filepath.Base(c.filename), c.currBuff.Bytes(), after.Bytes(), true)
+	return plan, err
+}
@@ -0,0 +1,105 @@
+package acfmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const expectedPlanDiff string = `--- a/credentials
++++ b/credentials
+@@ -1,8 +1,4 @@
+ 
+-[testing]
+-foo
+-bar
+-
+ [newentry]
+ bar
+ foo
+`
+
+func TestPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	for _, name := range []string{"testing", "brandnew"} {
+		err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: name})
+		if err != nil {
+			t.Fatalf("Error adding entry: %s", err)
+		}
+	}
+	plan, err := sess.Plan(PlanAssert)
+	if err != nil {
+		t.Fatalf("Error planning: %s", err)
+	}
+	want := []PlanChange{
+		{Action: ActionReplace, Profile: "testing"},
+		{Action: ActionCreate, Profile: "brandnew"},
+	}
+	if !reflect.DeepEqual(plan.Changes, want) {
+		t.Errorf("Unexpected changes. Have: %+v, Want: %+v", plan.Changes, want)
+	}
+	if strings.Contains(plan.Diff, getFakeCreds().SecretAccessKey) || strings.Contains(plan.Diff, getFakeCreds().SessionToken) {
+		t.Errorf("Diff leaked secrets: %s", plan.Diff)
+	}
+	if !strings.Contains(plan.Diff, "+aws_secret_access_key = ********") {
+		t.Errorf("Diff doesn't show masked secret: %s", plan.Diff)
+	}
+	if !strings.Contains(plan.Diff, "+aws_access_key_id = "+getFakeCreds().AccessKeyID) {
+		t.Errorf("Diff should show access key id: %s", plan.Diff)
+	}
+	got, _ := ioutil.ReadFile(filename)
+	if string(got) != baseCredFile {
+		t.Errorf("Plan modified the file: %s", got)
+	}
+
+	plan, err = sess.Plan(PlanDelete)
+	if err != nil {
+		t.Fatalf("Error planning: %s", err)
+	}
+	want = []PlanChange{{Action: ActionRemove, Profile: "testing"}}
+	if !reflect.DeepEqual(plan.Changes, want) {
+		t.Errorf("Unexpected changes. Have: %+v, Want: %+v", plan.Changes, want)
+	}
+	if plan.Diff != expectedPlanDiff {
+		t.Errorf("Unexpected diff. Have:\n%s\nWant:\n%s", plan.Diff, expectedPlanDiff)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		A, B string
+		Want string
+	}{
+		{A: "a b c", B: "a b c", Want: " a  b  c"},
+		{A: "a b c", B: "a c", Want: " a -b  c"},
+		{A: "a c", B: "a b c", Want: " a +b  c"},
+		{A: "a b c d", B: "x b y d", Want: "-a +x  b -c +y  d"},
+		{A: "", B: "a", Want: "+a"},
+	}
+	for _, c := range cases {
+		script := diffLines(strings.Fields(c.A), strings.Fields(c.B))
+		var have []string
+		for _, l := range script {
+			have = append(have, string(l.kind)+l.text)
+		}
+		if strings.Join(have, " ") != c.Want {
+			t.Errorf("Unexpected script for %q -> %q. Have: %q, Want: %q", c.A, c.B, strings.Join(have, " "), c.Want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package acfmgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ProcessCommand is the command written in credential_process
+// stubs. Change it if acfmgr isn't on the PATH of the SDK/CLI.
+var ProcessCommand = "acfmgr"
+
+// ErrNoProcessStore is returned when a CredentialProcess entry is
+// queued on a CredFile without a process store.
+var ErrNoProcessStore = errors.New("CredentialProcess entries need a process store, see SetProcessStore")
+
+// ErrProcessInTx is returned by Tx.Upsert for CredentialProcess
+// entries since they write to two files. Use NewEntry instead.
+var ErrProcessInTx = errors.New("CredentialProcess entries can't be added in a transaction")
+
+// ErrUnsafeProcessArg is returned when a profile name, store path or
+// ExpiresToken can't be safely quoted in a credential_process command.
+var ErrUnsafeProcessArg = errors.New("can't be quoted in a credential_process command")
+
+// ProcessCredentials is the JSON document the AWS SDKs and
+// CLI expect from a credential_process command.
+type ProcessCredentials struct {
+	Version         int
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+	Expiration      string `json:",omitempty"` // RFC3339, left out for credentials that don't expire
+}
+
+// NewProcessCredentials converts aws.Credentials to the
+// credential_process format.
+func NewProcessCredentials(creds aws.Credentials) *ProcessCredentials {
+	pc := &ProcessCredentials{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+	if creds.CanExpire {
+		pc.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	return pc
+}
+
+// WriteProcessCredentials reads the profile from the credentials file
+// and writes it to w as credential_process JSON. Expired profiles
+// return a *ProfileExpiredError so the SDK doesn't use them.
+func WriteProcessCredentials(w io.Writer, filename, profileName, expiresToken string) error {
+	pp := &ProfileProvider{Filename: filename, ProfileName: profileName, ExpiresToken: expiresToken}
+	creds, err := pp.Retrieve(context.Background())
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewProcessCredentials(creds))
+}
+
+// SetProcessStore attaches the credentials file that CredentialProcess
+// entries keep their keys in. It should be private to the user
+// running the SDK/CLI, e.g., '~/.aws/acfmgr/credentials'. Pass nil to
+// detach it.
+func (c *CredFile) SetProcessStore(store *CredFile) {
+	c.store = store
+}
+
+// checkProcessEntry makes sure a CredentialProcess entry can be queued
+func (c *CredFile) checkProcessEntry(pfi *ProfileEntryInput) error {
+	if c.store == nil {
+		return ErrNoProcessStore
+	}
+	if pfi.RoleProfile {
+		return fmt.Errorf("%w: %s: RoleProfile and CredentialProcess can't both be set", ErrInvalidRoleProfile, pfi.ProfileEntryName)
+	}
+	return nil
+}
+
+// queueProcessEntry queues the keys in the store and a stub that
+// runs 'acfmgr process' against the store in this file
+func (c *CredFile) queueProcessEntry(pfi *ProfileEntryInput, name string) error {
+	keys := *pfi
+	keys.Region = ""
+	keys.OutputFormat = ""
+	e, err := buildEntry(&keys, name)
+	if err != nil {
+		return err
+	}
+	cmd, err := processCommand(name, c.store.filename, pfi.ExpiresToken)
+	if err != nil {
+		return err
+	}
+	c.store.queue(e)
+	stub := buildConfigEntry(pfi, name)
+	stub.contents = append(stub.contents, "credential_process = "+cmd)
+	c.queue(stub)
+	return err
+}
+
+// processCommand builds the credential_process command line
+func processCommand(name, filename, expiresToken string) (cmd string, err error) {
+	args := []string{name, filename}
+	if expiresToken != "" {
+		args = append(args, expiresToken)
+	}
+	for i, a := range args {
+		args[i], err = quoteArg(a)
+		if err != nil {
+			return cmd, err
+		}
+	}
+	cmd = fmt.Sprintf("%s process --profile %s --file %s", ProcessCommand, args[0], args[1])
+	if expiresToken != "" {
+		cmd += " --expires-token " + args[2]
+	}
+	return cmd, err
+}
+
+// quoteArg wraps s in double quotes if the SDK/CLI would otherwise
+// split it up or a shell would interpret it. The SDKs run the command
+// through sh or cmd.exe and the CLI splits it like a POSIX shell, so
+// anything that means something inside double quotes to one of them
+// is rejected instead.
+func quoteArg(s string) (string, error) {
+	if strings.ContainsAny(s, "\"'$`%") || strings.HasSuffix(s, `\`) || strings.IndexFunc(s, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%w: %q", ErrUnsafeProcessArg, s)
+	}
+	if s == "" || strings.IndexFunc(s, needsQuote) >= 0 {
+		return `"` + s + `"`, nil
+	}
+	return s, nil
+}
+
+// needsQuote reports whether r has to be quoted
+// to be taken literally by a shell
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("-_./:@+=,\\", r):
+		return false
+	}
+	return true
+}
+
+// updateStore applies the entries queued in the process store, or
+// removes the profiles queued here when replace is false
+func (c *CredFile) updateStore(replace bool) error {
+	if c.store == nil {
+		return nil
+	}
+	ents := c.store.ents
+	if !replace {
+		ents = c.ents
+	}
+	return c.store.withLock(func() error {
+		return c.store.modifyEntries(replace, ents)
+	})
+}
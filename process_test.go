@@ -0,0 +1,163 @@
+package acfmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteProcessCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	fresh := getFakeCreds()
+	fresh.Expires = time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	assertProfiles(t, filename,
+		ProfileEntryInput{Credential: fresh, ProfileEntryName: "fresh"},
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "expired"},
+	)
+	var buf bytes.Buffer
+	err = WriteProcessCredentials(&buf, filename, "fresh", "")
+	if err != nil {
+		t.Fatalf("Error writing process credentials: %s", err)
+	}
+	var doc map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		t.Fatalf("Error parsing output: %s\n%s", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"Version":         float64(1),
+		"AccessKeyId":     fresh.AccessKeyID,
+		"SecretAccessKey": fresh.SecretAccessKey,
+		"SessionToken":    fresh.SessionToken,
+		"Expiration":      fresh.Expires.Format(time.RFC3339),
+	}
+	for k, v := range want {
+		if doc[k] != v {
+			t.Errorf("Unexpected %s. Have: %v, Want: %v", k, doc[k], v)
+		}
+	}
+	err = WriteProcessCredentials(&buf, filename, "expired", "")
+	if !errors.Is(err, ErrProfileExpired) {
+		t.Errorf("Expected ErrProfileExpired, got: %v", err)
+	}
+}
+
+func TestCredentialProcessStub(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	pfi := ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", Region: "us-east-1", CredentialProcess: true}
+	if err = sess.NewEntry(&pfi); !errors.Is(err, ErrNoProcessStore) {
+		t.Errorf("Expected ErrNoProcessStore, got: %v", err)
+	}
+	storeName := filepath.Join(dir, "private store", "credentials")
+	store, err := NewCredFileSession(storeName)
+	if err != nil {
+		t.Fatalf("Error making store session: %s", err)
+	}
+	sess.SetProcessStore(store)
+	if err = sess.Begin().Upsert(&pfi); !errors.Is(err, ErrProcessInTx) {
+		t.Errorf("Expected ErrProcessInTx, got: %v", err)
+	}
+	err = sess.NewEntry(&pfi)
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	p, err := sess.GetProfile("dev")
+	if err != nil {
+		t.Fatalf("Error getting stub profile: %s", err)
+	}
+	want := `acfmgr process --profile dev --file "` + storeName + `"`
+	if p.Values["credential_process"] != want || p.Values["region"] != "us-east-1" {
+		t.Errorf("Unexpected stub. Have: %v, Want: %s", p.Values, want)
+	}
+	if _, ok := p.Values["aws_secret_access_key"]; ok {
+		t.Errorf("Secrets written to the shared file: %v", p.Values)
+	}
+	p, err = store.GetProfile("dev")
+	if err != nil || !p.Managed || p.Values["aws_secret_access_key"] != pfi.Credential.SecretAccessKey {
+		t.Errorf("Expected keys in the store, got: %v %v", p, err)
+	}
+	if strings.Contains(strings.Join(p.Keys, ","), "region") {
+		t.Errorf("Settings written to the store: %v", p.Keys)
+	}
+	err = sess.DeleteEntries()
+	if err != nil {
+		t.Fatalf("Error deleting entries: %s", err)
+	}
+	if len(sess.ListProfiles()) != 0 || len(store.ListProfiles()) != 0 {
+		t.Errorf("Expected both files to be empty")
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	cases := []struct {
+		Arg         string
+		Want        string
+		ExpectedErr error
+	}{
+		{"dev", "dev", nil},
+		{`C:\Users\me\.aws\acfmgr`, `C:\Users\me\.aws\acfmgr`, nil},
+		{"/home/me/private store/credentials", `"/home/me/private store/credentials"`, nil},
+		{"/home/me/a;b&c|d", `"/home/me/a;b&c|d"`, nil},
+		{"", `""`, nil},
+		{`/home/me/"quoted"`, "", ErrUnsafeProcessArg},
+		{"/home/me/o'brien", "", ErrUnsafeProcessArg},
+		{"/home/$USER/creds", "", ErrUnsafeProcessArg},
+		{"/home/`id`/creds", "", ErrUnsafeProcessArg},
+		{`C:\%USERPROFILE%\creds`, "", ErrUnsafeProcessArg},
+		{`C:\creds\`, "", ErrUnsafeProcessArg},
+		{"line\nbreak", "", ErrUnsafeProcessArg},
+	}
+	for _, c := range cases {
+		have, err := quoteArg(c.Arg)
+		if !errors.Is(err, c.ExpectedErr) || have != c.Want {
+			t.Errorf("Unexpected result for %q. Have: %s %v, Want: %s %v", c.Arg, have, err, c.Want, c.ExpectedErr)
+		}
+	}
+}
+
+func TestCredentialProcessUnsafeStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess, err := NewCredFileSession(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	store, err := NewCredFileSession(filepath.Join(dir, "$HOME", "credentials"))
+	if err != nil {
+		t.Fatalf("Error making store session: %s", err)
+	}
+	sess.SetProcessStore(store)
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "dev", CredentialProcess: true})
+	if !errors.Is(err, ErrUnsafeProcessArg) {
+		t.Errorf("Expected ErrUnsafeProcessArg, got: %v", err)
+	}
+	if len(store.ents) != 0 || len(sess.ents) != 0 {
+		t.Errorf("Entries queued despite the error")
+	}
+}
@@ -0,0 +1,74 @@
+package acfmgr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// managedMarker is the comment line the default template
+// writes so we can tell our sections from hand written ones
+const managedMarker = "# ACFMGR MANAGED SECTION"
+
+// ErrProfileNotFound is returned when a requested profile
+// does not exist in the credentials file.
+var ErrProfileNotFound = errors.New("profile not found")
+
+// Profile is a parsed representation of a single
+// profile section from a credentials file.
+type Profile struct {
+	Name     string            // name of the profile without brackets e.g., 'devaccount'
+	Values   map[string]string // key/value pairs found in the section e.g., 'region' => 'us-east-1'
+	Keys     []string          // keys in the order they appear in the section
+	Comments []string          // comment lines found in the section
+	Managed  bool              // whether the section was written by acfmgr
+	lines    []string          // raw lines of the section body
+}
+
+// ListProfiles returns all of the profiles currently found
+// in the CredFile in the order they appear in the file.
+func (c *CredFile) ListProfiles() (profiles []*Profile) {
+	return parseProfiles(c.document())
+}
+
+// GetProfile returns the profile with the given name. Brackets
+// around the name are optional. If there are multiple sections
+// with the same name the first one is returned.
+func (c *CredFile) GetProfile(name string) (*Profile, error) {
+	name = strings.Trim(name, "[]")
+	for _, p := range c.ListProfiles() {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+}
+
+// parseProfiles builds a Profile for every section in the
+// document. Anything before the first section header is ignored.
+func parseProfiles(doc *document) (profiles []*Profile) {
+	for _, s := range doc.sections {
+		if s.header == nil {
+			continue
+		}
+		p := &Profile{Name: s.name(), Values: make(map[string]string)}
+		for _, t := range s.body {
+			p.lines = append(p.lines, t.text())
+			switch t.kind {
+			case tokComment:
+				trimmed := strings.TrimSpace(t.text())
+				p.Comments = append(p.Comments, trimmed)
+				if trimmed == managedMarker {
+					p.Managed = true
+				}
+			case tokKeyValue:
+				if _, exists := p.Values[t.key]; !exists {
+					p.Keys = append(p.Keys, t.key)
+				}
+				p.Values[t.key] = t.value
+			}
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
@@ -0,0 +1,63 @@
+package acfmgr
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestListProfiles(t *testing.T) {
+	filename := "./acfmgr_credfile_test_list.txt"
+	err := writeBaseFile(filename)
+	if err != nil {
+		t.Errorf("Error making basefile: %s", err)
+	}
+	defer os.Remove(filename)
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	pfi := ProfileEntryInput{
+		Credential:       getFakeCreds(),
+		ProfileEntryName: "acfmgrtest",
+		Region:           "us-east-1",
+	}
+	err = sess.NewEntry(&pfi)
+	if err != nil {
+		t.Errorf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Error asserting entries: %s", err)
+	}
+	profiles := sess.ListProfiles()
+	want := []string{"testing", "newentry", "acfmgrtest"}
+	if len(profiles) != len(want) {
+		t.Fatalf("Unexpected number of profiles. Have: %d, Want: %d", len(profiles), len(want))
+	}
+	for i, p := range profiles {
+		if p.Name != want[i] {
+			t.Errorf("Unexpected profile name. Have: '%s', Want: '%s'", p.Name, want[i])
+		}
+	}
+	if profiles[0].Managed {
+		t.Errorf("Hand written profile reported as managed")
+	}
+	p, err := sess.GetProfile("[acfmgrtest]")
+	if err != nil {
+		t.Fatalf("Error getting profile: %s", err)
+	}
+	if !p.Managed {
+		t.Errorf("Managed profile not reported as managed")
+	}
+	if p.Values["region"] != "us-east-1" {
+		t.Errorf("Unexpected region. Have: '%s'", p.Values["region"])
+	}
+	if p.Values["aws_access_key_id"] != getFakeCreds().AccessKeyID {
+		t.Errorf("Unexpected access key. Have: '%s'", p.Values["aws_access_key_id"])
+	}
+	_, err = sess.GetProfile("nope")
+	if !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got: %v", err)
+	}
+}
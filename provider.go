@@ -0,0 +1,104 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ProviderSource is the value used for aws.Credentials.Source
+// when credentials are read back out of a credentials file.
+const ProviderSource = "AcfmgrProvider"
+
+// ErrProfileExpired can be used with errors.Is to check
+// for a ProfileExpiredError.
+var ErrProfileExpired = errors.New("profile credentials expired")
+
+// ErrMissingKeys is returned when a profile is missing
+// aws_access_key_id or aws_secret_access_key.
+var ErrMissingKeys = errors.New("profile is missing access keys")
+
+// ProfileExpiredError is returned by ProfileProvider when
+// the managed header says the credentials have expired.
+type ProfileExpiredError struct {
+	Profile string
+	Expires time.Time
+}
+
+func (e *ProfileExpiredError) Error() string {
+	return fmt.Sprintf("%s: %s expired at %s", ErrProfileExpired, e.Profile, e.Expires)
+}
+
+// Is lets errors.Is match a ProfileExpiredError against ErrProfileExpired
+func (e *ProfileExpiredError) Is(target error) bool {
+	return target == ErrProfileExpired
+}
+
+// Credentials builds an aws.Credentials from the keys in the
+// profile. For managed profiles Expires and CanExpire are
+// filled in from the header using the given expiresToken
+// (blank for the package default).
+func (p *Profile) Credentials(expiresToken string) (creds aws.Credentials, err error) {
+	creds = aws.Credentials{
+		AccessKeyID:     p.Values["aws_access_key_id"],
+		SecretAccessKey: p.Values["aws_secret_access_key"],
+		SessionToken:    p.Values["aws_session_token"],
+		Source:          ProviderSource,
+	}
+	if !creds.HasKeys() {
+		return creds, fmt.Errorf("%w: %s", ErrMissingKeys, p.Name)
+	}
+	if p.Managed {
+		md, err := p.Metadata(expiresToken)
+		if err != nil {
+			return creds, err
+		}
+		if md.HasExpiry() {
+			creds.CanExpire = true
+			creds.Expires = md.Expires
+		}
+	}
+	return creds, err
+}
+
+// ProfileProvider implements aws.CredentialsProvider by reading a
+// named profile out of a credentials file every time Retrieve is
+// called. Wrap it in an aws.CredentialsCache to avoid rereading
+// the file for every request.
+type ProfileProvider struct {
+	Filename     string // MANDATORY: credentials file to read e.g., '~/.aws/credentials'
+	ProfileName  string // MANDATORY: name of the profile to read
+	ExpiresToken string // OPTIONAL: the ExpiresToken used when the entry was written
+}
+
+// NewProfileProvider returns a ProfileProvider for the
+// given file and profile name.
+func NewProfileProvider(filename, profileName string) *ProfileProvider {
+	return &ProfileProvider{Filename: filename, ProfileName: profileName}
+}
+
+// Retrieve reads the profile and returns its credentials. Returns an
+// error wrapping ErrProfileNotFound if the profile doesn't exist, one
+// wrapping os.ErrNotExist if the file doesn't or a *ProfileExpiredError
+// if the managed header says it has expired. Nothing is ever created.
+func (pp *ProfileProvider) Retrieve(ctx context.Context) (creds aws.Credentials, err error) {
+	c, err := OpenCredFile(pp.Filename)
+	if err != nil {
+		return creds, err
+	}
+	p, err := c.GetProfile(pp.ProfileName)
+	if err != nil {
+		return creds, err
+	}
+	creds, err = p.Credentials(pp.ExpiresToken)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	if creds.Expired() {
+		return aws.Credentials{}, &ProfileExpiredError{Profile: p.Name, Expires: creds.Expires}
+	}
+	return creds, err
+}
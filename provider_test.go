@@ -0,0 +1,94 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// assertProfiles is a helper to write a base file plus the given
+// entries and return a session for it
+func assertProfiles(t *testing.T, filename string, inputs ...ProfileEntryInput) *CredFile {
+	t.Helper()
+	err := writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	for i := range inputs {
+		err = sess.NewEntry(&inputs[i])
+		if err != nil {
+			t.Fatalf("Error adding entry: %s", err)
+		}
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	return sess
+}
+
+func TestProfileProvider(t *testing.T) {
+	filename := "./acfmgr_credfile_test_provider.txt"
+	defer os.Remove(filename)
+	fresh := getFakeCreds()
+	fresh.Expires = time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	assertProfiles(t, filename,
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "expired"},
+		ProfileEntryInput{Credential: fresh, ProfileEntryName: "fresh"},
+	)
+	var _ aws.CredentialsProvider = &ProfileProvider{}
+
+	creds, err := NewProfileProvider(filename, "fresh").Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Error retrieving creds: %s", err)
+	}
+	if !creds.CanExpire || !creds.Expires.Equal(fresh.Expires) {
+		t.Errorf("Unexpected expiry. Have: %v %s, Want: %s", creds.CanExpire, creds.Expires, fresh.Expires)
+	}
+	if creds.AccessKeyID != fresh.AccessKeyID || creds.SessionToken != fresh.SessionToken {
+		t.Errorf("Unexpected keys: %+v", creds)
+	}
+
+	_, err = NewProfileProvider(filename, "expired").Retrieve(context.Background())
+	var expErr *ProfileExpiredError
+	if !errors.As(err, &expErr) || !errors.Is(err, ErrProfileExpired) {
+		t.Errorf("Expected ProfileExpiredError, got: %v", err)
+	}
+
+	_, err = NewProfileProvider(filename, "missing").Retrieve(context.Background())
+	if !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got: %v", err)
+	}
+
+	// hand written profile without keys
+	_, err = NewProfileProvider(filename, "testing").Retrieve(context.Background())
+	if !errors.Is(err, ErrMissingKeys) {
+		t.Errorf("Expected ErrMissingKeys, got: %v", err)
+	}
+}
+
+func TestProfileProviderMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "aws", "credentials")
+	_, err = NewProfileProvider(filename, "dev").Retrieve(context.Background())
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got: %v", err)
+	}
+	if _, err = os.Stat(filepath.Dir(filename)); !os.IsNotExist(err) {
+		t.Errorf("Retrieve created %s", filepath.Dir(filename))
+	}
+}
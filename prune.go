@@ -0,0 +1,55 @@
+package acfmgr
+
+import (
+	"fmt"
+	"time"
+)
+
+// PruneExpired removes acfmgr managed profile sections whose
+// recorded expiry is before now minus the grace period. Hand written
+// profiles and managed sections without a parseable expiry are left
+// alone. If a profile name appears more than once it's only pruned
+// when every section with that name is expired. Returns the names of
+// the profiles that were removed. expiresToken is the ExpiresToken
+// used when the entries were written, blank for the default.
+func (c *CredFile) PruneExpired(grace time.Duration, expiresToken string) (pruned []string, err error) {
+	err = c.withLock(func() error {
+		pruned, err = c.pruneExpired(grace, expiresToken)
+		return err
+	})
+	return pruned, err
+}
+
+func (c *CredFile) pruneExpired(grace time.Duration, expiresToken string) (pruned []string, err error) {
+	cutoff := time.Now().Add(-grace)
+	expired := make(map[string]bool)
+	var order []string
+	for _, p := range c.ListProfiles() {
+		prunable := false
+		if p.Managed {
+			md, err := p.Metadata(expiresToken)
+			if err == nil && md.HasExpiry() && md.Expires.Before(cutoff) {
+				prunable = true
+			}
+		}
+		prev, seen := expired[p.Name]
+		if !seen {
+			order = append(order, p.Name)
+			expired[p.Name] = prunable
+		} else {
+			expired[p.Name] = prev && prunable
+		}
+	}
+	var ents []*credEntry
+	for _, name := range order {
+		if expired[name] {
+			ents = append(ents, &credEntry{name: fmt.Sprintf("[%s]", name)})
+			pruned = append(pruned, name)
+		}
+	}
+	err = c.modifyEntries(false, ents)
+	if err != nil {
+		return nil, err
+	}
+	return pruned, err
+}
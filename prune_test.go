@@ -0,0 +1,91 @@
+package acfmgr
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPruneExpired(t *testing.T) {
+	filename := "./acfmgr_credfile_test_prune.txt"
+	err := writeBaseFile(filename)
+	if err != nil {
+		t.Errorf("Error making basefile: %s", err)
+	}
+	defer os.Remove(filename)
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	fresh := getFakeCreds()
+	fresh.Expires = time.Now().Add(time.Hour)
+	inputs := []ProfileEntryInput{
+		{Credential: getFakeCreds(), ProfileEntryName: "expired"},
+		{Credential: fresh, ProfileEntryName: "fresh"},
+	}
+	for i := range inputs {
+		err = sess.NewEntry(&inputs[i])
+		if err != nil {
+			t.Errorf("Error adding entry: %s", err)
+		}
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Error asserting entries: %s", err)
+	}
+	pruned, err := sess.PruneExpired(time.Minute, "")
+	if err != nil {
+		t.Fatalf("Error pruning: %s", err)
+	}
+	if len(pruned) != 1 || pruned[0] != "expired" {
+		t.Errorf("Unexpected pruned profiles: %v", pruned)
+	}
+	var names []string
+	for _, p := range sess.ListProfiles() {
+		names = append(names, p.Name)
+	}
+	want := []string{"testing", "newentry", "fresh"}
+	if len(names) != len(want) {
+		t.Fatalf("Unexpected profiles left. Have: %v, Want: %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Unexpected profiles left. Have: %v, Want: %v", names, want)
+		}
+	}
+	// a huge grace period should spare everything
+	pruned, err = sess.PruneExpired(24*time.Hour*365*100, "")
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("Expected nothing pruned. Have: %v, %v", pruned, err)
+	}
+}
+
+func TestPruneExpiredCustomToken(t *testing.T) {
+	filename := "./acfmgr_credfile_test_prune_token.txt"
+	err := writeBaseFile(filename)
+	if err != nil {
+		t.Errorf("Error making basefile: %s", err)
+	}
+	defer os.Remove(filename)
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "expired", ExpiresToken: "VALID_UNTIL:"})
+	if err != nil {
+		t.Errorf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Errorf("Error asserting entries: %s", err)
+	}
+	// the default token can't find the expiry so it's left alone
+	pruned, err := sess.PruneExpired(time.Minute, "")
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("Expected nothing pruned with the default token. Have: %v, %v", pruned, err)
+	}
+	pruned, err = sess.PruneExpired(time.Minute, "VALID_UNTIL:")
+	if err != nil || len(pruned) != 1 || pruned[0] != "expired" {
+		t.Errorf("Unexpected pruned profiles: %v, %v", pruned, err)
+	}
+}
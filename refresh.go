@@ -0,0 +1,295 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// defaults for the zero values of a Refresher
+const (
+	DefaultLeadTime        = 10 * time.Minute
+	DefaultRefreshBackoff  = 30 * time.Second
+	DefaultRefreshMaxDelay = 15 * time.Minute
+	DefaultPollInterval    = time.Minute
+)
+
+// ErrLeadTimeTooLong is reported to Refresher.OnError when fresh
+// credentials don't last longer than the lead time, e.g., a LeadTime
+// of an hour with 15 minute STS credentials. The profile is refreshed
+// again after Backoff instead of straight away.
+var ErrLeadTimeTooLong = errors.New("credentials expire within the refresh lead time")
+
+// CredentialSource returns fresh credentials for a managed profile
+// that's about to expire, e.g., by assuming p's role again.
+type CredentialSource func(ctx context.Context, p *Profile) (*aws.Credentials, error)
+
+// Clock is the time source used by a Refresher so tests
+// can control it.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock used when none is given
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Refresher keeps the managed profiles in a credentials file fresh. It
+// calls Source some time before each profile's EXPIRES@ time and writes
+// the new credentials over the old ones without moving the section.
+// Failed refreshes are retried with exponential backoff. Build one with
+// the fields below and call Run.
+type Refresher struct {
+	Filename         string                          // MANDATORY: credentials file to watch e.g., '~/.aws/credentials'
+	Source           CredentialSource                // MANDATORY: where fresh credentials come from
+	Profiles         []string                        // OPTIONAL: only refresh these profiles, defaults to every managed profile with an expiry
+	ExpiresToken     string                          // OPTIONAL: the ExpiresToken used when the entries were written
+	TemplateOverride *template.Template              // OPTIONAL: the template used when the entries were written
+	LeadTime         time.Duration                   // OPTIONAL: refresh this long before expiry, defaults to DefaultLeadTime
+	Jitter           time.Duration                   // OPTIONAL: refresh up to this much earlier again at random so several refreshers don't all call Source at once
+	Backoff          time.Duration                   // OPTIONAL: wait before retrying a failed refresh, doubled each time. Defaults to DefaultRefreshBackoff
+	MaxBackoff       time.Duration                   // OPTIONAL: the longest wait between retries, defaults to DefaultRefreshMaxDelay
+	PollInterval     time.Duration                   // OPTIONAL: how often to reread the file for new or changed profiles, defaults to DefaultPollInterval
+	Clock            Clock                           // OPTIONAL: defaults to the system clock
+	OnError          func(profile string, err error) // OPTIONAL: called for every failure, profile is blank when the file itself couldn't be read or written
+	mu               sync.Mutex
+	state            map[string]*refreshState
+}
+
+// refreshState tracks when a profile is next due
+type refreshState struct {
+	expires  time.Time // the expiry the schedule was worked out for
+	due      time.Time
+	failures int
+}
+
+// Run refreshes profiles as they come due until ctx is done and then
+// returns ctx.Err().
+func (r *Refresher) Run(ctx context.Context) error {
+	clock := r.clock()
+	for {
+		next, err := r.RefreshDue(ctx)
+		if err != nil {
+			r.report("", err)
+		}
+		wait := r.pollInterval()
+		if !next.IsZero() {
+			if d := next.Sub(clock.Now()); d < wait {
+				wait = d
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// RefreshDue rereads the file, refreshes every profile that's due and
+// writes them all in one commit. Returns when the next profile is due
+// or a zero time if nothing is scheduled. Failures of single profiles
+// go to OnError, err is for problems with the file itself.
+func (r *Refresher) RefreshDue(ctx context.Context) (next time.Time, err error) {
+	if r.Source == nil {
+		return next, errors.New("Refresher requires a Source")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == nil {
+		r.state = make(map[string]*refreshState)
+	}
+	c, err := NewCredFileSession(r.Filename)
+	if err != nil {
+		return next, err
+	}
+	now := r.clock().Now()
+	tx := c.Begin()
+	var refreshed []string
+	seen := make(map[string]bool)
+	for _, p := range c.ListProfiles() {
+		if seen[p.Name] || !r.watching(p) {
+			continue
+		}
+		seen[p.Name] = true
+		md, err := p.Metadata(r.ExpiresToken)
+		if err != nil || !md.HasExpiry() {
+			continue
+		}
+		st := r.schedule(p.Name, md.Expires)
+		if now.Before(st.due) {
+			next = earliest(next, st.due)
+			continue
+		}
+		if ctx.Err() != nil {
+			return next, ctx.Err()
+		}
+		e, err := r.refresh(ctx, p, md)
+		if err != nil {
+			st.failures++
+			st.due = now.Add(r.backoff(st.failures))
+			next = earliest(next, st.due)
+			r.report(p.Name, err)
+			continue
+		}
+		tx.ops = append(tx.ops, txOp{kind: opRefresh, name: p.Name, entry: e})
+		refreshed = append(refreshed, p.Name)
+	}
+	// forget profiles that have gone away
+	for name := range r.state {
+		if !seen[name] {
+			delete(r.state, name)
+		}
+	}
+	if len(refreshed) == 0 {
+		return next, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		// the new credentials are lost so back off like any other failure
+		for _, name := range refreshed {
+			st := r.state[name]
+			st.failures++
+			st.due = now.Add(r.backoff(st.failures))
+			next = earliest(next, st.due)
+		}
+		return next, err
+	}
+	// work out the new schedule from what was just written
+	floor := now.Add(r.backoff(1))
+	fresh := make(map[string]bool)
+	for _, name := range refreshed {
+		fresh[name] = true
+	}
+	for _, p := range c.ListProfiles() {
+		md, err := p.Metadata(r.ExpiresToken)
+		if err != nil || !md.HasExpiry() || !r.watching(p) {
+			continue
+		}
+		st := r.schedule(p.Name, md.Expires)
+		if fresh[p.Name] && st.due.Before(floor) {
+			// don't call Source again right away for short lived credentials
+			st.due = floor
+			r.report(p.Name, fmt.Errorf("%w: %s expires at %s", ErrLeadTimeTooLong, p.Name, md.Expires))
+		}
+		next = earliest(next, st.due)
+	}
+	return next, nil
+}
+
+// refresh gets new credentials for p and renders the entry that
+// replaces it, keeping the metadata and settings it had before
+func (r *Refresher) refresh(ctx context.Context, p *Profile, md *ManagedEntryMetadata) (*credEntry, error) {
+	creds, err := r.Source(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil || !creds.HasKeys() {
+		return nil, ErrMissingKeys
+	}
+	return buildEntry(&ProfileEntryInput{
+		Credential:       creds,
+		ProfileEntryName: p.Name,
+		Region:           p.Values["region"],
+		OutputFormat:     p.Values["output"],
+		ExpiresToken:     r.ExpiresToken,
+		InstanceRoleARN:  md.InstanceRoleARN,
+		AssumeRoleARN:    md.AssumeRoleARN,
+		Description:      md.Description,
+		TemplateOverride: r.TemplateOverride,
+	}, p.Name)
+}
+
+// schedule returns the state for a profile, working out a new due
+// time when the expiry has changed since we last looked
+func (r *Refresher) schedule(name string, expires time.Time) *refreshState {
+	st, ok := r.state[name]
+	if ok && st.expires.Equal(expires) {
+		return st
+	}
+	lead := r.LeadTime
+	if lead <= 0 {
+		lead = DefaultLeadTime
+	}
+	if r.Jitter > 0 {
+		lead += time.Duration(rand.Int63n(int64(r.Jitter)))
+	}
+	st = &refreshState{expires: expires, due: expires.Add(-lead)}
+	r.state[name] = st
+	return st
+}
+
+// watching reports whether p is one of the profiles to refresh
+func (r *Refresher) watching(p *Profile) bool {
+	if !p.Managed {
+		return false
+	}
+	if len(r.Profiles) == 0 {
+		return true
+	}
+	for _, name := range r.Profiles {
+		if cleanProfileName(name) == p.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait after the given number of failures
+func (r *Refresher) backoff(failures int) time.Duration {
+	d := r.Backoff
+	if d <= 0 {
+		d = DefaultRefreshBackoff
+	}
+	max := r.MaxBackoff
+	if max <= 0 {
+		max = DefaultRefreshMaxDelay
+	}
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (r *Refresher) pollInterval() time.Duration {
+	if r.PollInterval <= 0 {
+		return DefaultPollInterval
+	}
+	return r.PollInterval
+}
+
+func (r *Refresher) clock() Clock {
+	if r.Clock == nil {
+		return systemClock{}
+	}
+	return r.Clock
+}
+
+func (r *Refresher) report(profile string, err error) {
+	if r.OnError != nil {
+		r.OnError(profile, err)
+	}
+}
+
+// earliest returns the earlier of a and b treating zero as unset
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,323 @@
+package acfmgr
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// fakeClock only moves when Advance is called
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{f.now.Add(d), ch})
+	return ch
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	kept := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			kept = append(kept, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = kept
+}
+
+// Waiting reports how many After calls are pending
+func (f *fakeClock) Waiting() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// refresherSource hands out credentials that expire an hour after
+// the clock's current time and counts the calls
+type refresherSource struct {
+	mu    sync.Mutex
+	clock    *fakeClock
+	calls    map[string]int
+	fail     int           // fail this many calls first
+	lifetime time.Duration // how long the credentials last, defaults to an hour
+}
+
+func (s *refresherSource) source(ctx context.Context, p *Profile) (*aws.Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return nil, errors.New("sts unavailable")
+	}
+	s.calls[p.Name]++
+	lifetime := s.lifetime
+	if lifetime == 0 {
+		lifetime = time.Hour
+	}
+	return &aws.Credentials{
+		AccessKeyID:     "ASIAFRESH" + p.Name,
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		CanExpire:       true,
+		Expires:         s.clock.Now().Add(lifetime),
+	}, nil
+}
+
+func (s *refresherSource) count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[name]
+}
+
+func TestRefresherRefreshDue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	clock := &fakeClock{now: getFakeCreds().Expires.Add(-5 * time.Minute)}
+	later := getFakeCreds()
+	later.Expires = clock.now.Add(time.Hour)
+	assertProfiles(t, filename,
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "soon", Region: "us-east-2", AssumeRoleARN: "arn:aws:iam::123456789012:role/soon", Description: "keep me"},
+		ProfileEntryInput{Credential: later, ProfileEntryName: "later"},
+	)
+	before, _ := ioutil.ReadFile(filename)
+	src := &refresherSource{clock: clock, calls: make(map[string]int)}
+	var errs []string
+	r := &Refresher{
+		Filename: filename,
+		Source:   src.source,
+		Clock:    clock,
+		OnError:  func(profile string, err error) { errs = append(errs, profile) },
+	}
+	next, err := r.RefreshDue(context.Background())
+	if err != nil {
+		t.Fatalf("Error refreshing: %s", err)
+	}
+	if src.count("soon") != 1 || src.count("later") != 0 {
+		t.Errorf("Unexpected Source calls: %v", src.calls)
+	}
+	// later is due 10 minutes before its expiry
+	if want := later.Expires.Add(-DefaultLeadTime); !next.Equal(want) {
+		t.Errorf("Unexpected next due time. Have: %s, Want: %s", next, want)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	p, err := sess.GetProfile("soon")
+	if err != nil {
+		t.Fatalf("Error getting profile: %s", err)
+	}
+	md, _ := p.Metadata("")
+	if p.Values["aws_access_key_id"] != "ASIAFRESHsoon" || p.Values["region"] != "us-east-2" {
+		t.Errorf("Unexpected refreshed values: %v", p.Values)
+	}
+	if md.AssumeRoleARN != "arn:aws:iam::123456789012:role/soon" || md.Description != "keep me" || !md.Expires.Equal(clock.now.Add(time.Hour)) {
+		t.Errorf("Metadata not kept: %+v", md)
+	}
+	// refreshed in place so the profiles keep their order
+	after, _ := ioutil.ReadFile(filename)
+	if strings.Index(string(after), "[soon]") != strings.Index(string(before), "[soon]") {
+		t.Errorf("Refreshed section moved:\n%s", after)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors for: %v", errs)
+	}
+}
+
+func TestRefresherBackoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	clock := &fakeClock{now: getFakeCreds().Expires}
+	assertProfiles(t, filename, ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "soon"})
+	src := &refresherSource{clock: clock, calls: make(map[string]int), fail: 3}
+	r := &Refresher{Filename: filename, Source: src.source, Clock: clock, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		next, err := r.RefreshDue(context.Background())
+		if err != nil {
+			t.Fatalf("Error refreshing: %s", err)
+		}
+		if !next.Equal(clock.Now().Add(want)) {
+			t.Errorf("Unexpected retry time. Have: %s, Want: %s", next, clock.Now().Add(want))
+		}
+		// not due yet so Source isn't called
+		r.RefreshDue(context.Background())
+		clock.Advance(want)
+	}
+	if _, err = r.RefreshDue(context.Background()); err != nil || src.count("soon") != 1 {
+		t.Errorf("Expected refresh after backoff, got: %v, calls: %v", err, src.calls)
+	}
+}
+
+func TestRefresherRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	clock := &fakeClock{now: getFakeCreds().Expires.Add(-time.Hour)}
+	assertProfiles(t, filename, ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "soon"})
+	src := &refresherSource{clock: clock, calls: make(map[string]int)}
+	r := &Refresher{Filename: filename, Source: src.source, Clock: clock, LeadTime: 5 * time.Minute, Jitter: time.Minute, PollInterval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(func() bool { return clock.Waiting() == 1 })
+	if src.count("soon") != 0 {
+		t.Errorf("Refreshed before it was due")
+	}
+	// lead time plus at most a minute of jitter before expiry
+	clock.Advance(time.Hour - 5*time.Minute)
+	waitFor(func() bool { return src.count("soon") == 1 })
+	waitFor(func() bool { return clock.Waiting() == 1 })
+	cancel()
+	if err = <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Run, got: %v", err)
+	}
+}
+
+func TestRefresherShortLivedCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	clock := &fakeClock{now: getFakeCreds().Expires}
+	assertProfiles(t, filename, ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "soon"})
+	// 15 minute credentials with an hour of lead time are
+	// already due as soon as they're written
+	src := &refresherSource{clock: clock, calls: make(map[string]int), lifetime: 15 * time.Minute}
+	var mu sync.Mutex
+	var errs []error
+	r := &Refresher{
+		Filename:     filename,
+		Source:       src.source,
+		Clock:        clock,
+		LeadTime:     time.Hour,
+		Backoff:      time.Minute,
+		PollInterval: time.Hour,
+		OnError: func(profile string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.Waiting() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// give a busy loop the chance to show itself
+	time.Sleep(50 * time.Millisecond)
+	if n := src.count("soon"); n != 1 {
+		t.Errorf("Unexpected Source calls right after refreshing. Have: %d, Want: 1", n)
+	}
+	cancel()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrLeadTimeTooLong) {
+		t.Errorf("Expected ErrLeadTimeTooLong to be reported, got: %v", errs)
+	}
+	next, err := r.RefreshDue(context.Background())
+	if err != nil || !next.Equal(clock.Now().Add(time.Minute)) || src.count("soon") != 1 {
+		t.Errorf("Expected the next refresh a Backoff later. Have: %s %v, calls: %v", next, err, src.calls)
+	}
+}
+
+func TestRefresherCommitFailureBacksOff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ownership isn't checked on windows")
+	}
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	clock := &fakeClock{now: getFakeCreds().Expires}
+	assertProfiles(t, filename, ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "soon"})
+	src := &refresherSource{clock: clock, calls: make(map[string]int)}
+	r := &Refresher{Filename: filename, Source: src.source, Clock: clock, Backoff: time.Second}
+	// pretend to be someone else so the write is refused
+	orig := currentUID
+	currentUID = func() int { return orig() + 1 }
+	defer func() { currentUID = orig }()
+	next, err := r.RefreshDue(context.Background())
+	if !errors.Is(err, ErrForeignOwner) {
+		t.Fatalf("Expected ErrForeignOwner, got: %v", err)
+	}
+	if !next.Equal(clock.Now().Add(time.Second)) {
+		t.Errorf("Unexpected retry time. Have: %s, Want: %s", next, clock.Now().Add(time.Second))
+	}
+	// not due yet so Source isn't called again
+	r.RefreshDue(context.Background())
+	if src.count("soon") != 1 {
+		t.Errorf("Source called again before the backoff. Calls: %v", src.calls)
+	}
+	clock.Advance(time.Second)
+	next, _ = r.RefreshDue(context.Background())
+	if src.count("soon") != 2 || !next.Equal(clock.Now().Add(2*time.Second)) {
+		t.Errorf("Expected a retry with doubled backoff. Have: %s, calls: %v", next, src.calls)
+	}
+}
@@ -0,0 +1,124 @@
+package acfmgr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidRoleProfile is returned when a RoleProfile
+// ProfileEntryInput is missing settings or has conflicting ones.
+var ErrInvalidRoleProfile = errors.New("invalid role profile")
+
+// ErrSourceProfileNotFound is returned when the SourceProfile of a
+// RoleProfile isn't in the file or queued in the session.
+var ErrSourceProfileNotFound = errors.New("source profile not found")
+
+// the range AWS allows for duration_seconds
+const (
+	minRoleDuration = 900
+	maxRoleDuration = 43200
+)
+
+// instanceCredentialSource is used for role profiles
+// built from entries with an InstanceRoleARN
+const instanceCredentialSource = "Ec2InstanceMetadata"
+
+// credentialSources are the values the SDKs and CLI
+// accept for credential_source
+var credentialSources = []string{"Environment", instanceCredentialSource, "EcsContainer"}
+
+// roleKeys are all of the keys roleLines can write
+var roleKeys = []string{"role_arn", "source_profile", "credential_source", "mfa_serial", "external_id", "role_session_name", "duration_seconds"}
+
+// roleLines renders the settings that have the SDK/CLI assume
+// the role in the order the AWS docs list them
+func roleLines(pfi *ProfileEntryInput) (lines []string) {
+	lines = append(lines, "role_arn = "+pfi.AssumeRoleARN)
+	if pfi.SourceProfile != "" {
+		lines = append(lines, "source_profile = "+strings.Trim(pfi.SourceProfile, "[]"))
+	} else {
+		lines = append(lines, "credential_source = "+credentialSource(pfi))
+	}
+	if pfi.MFASerial != "" {
+		lines = append(lines, "mfa_serial = "+pfi.MFASerial)
+	}
+	if pfi.ExternalID != "" {
+		lines = append(lines, "external_id = "+pfi.ExternalID)
+	}
+	if pfi.RoleSessionName != "" {
+		lines = append(lines, "role_session_name = "+pfi.RoleSessionName)
+	}
+	if pfi.DurationSeconds != 0 {
+		lines = append(lines, "duration_seconds = "+strconv.Itoa(pfi.DurationSeconds))
+	}
+	return lines
+}
+
+// credentialSource returns the CredentialSource falling back
+// to instance metadata when the entry has an InstanceRoleARN
+func credentialSource(pfi *ProfileEntryInput) string {
+	if pfi.CredentialSource == "" && pfi.InstanceRoleARN != "" {
+		return instanceCredentialSource
+	}
+	return pfi.CredentialSource
+}
+
+// validCredentialSource reports whether s is one of credentialSources
+func validCredentialSource(s string) bool {
+	for _, v := range credentialSources {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRoleProfile makes sure a RoleProfile entry can be written and
+// that its SourceProfile exists in this file, the queue, the
+// attached ConfigFile or the linked credentials file
+func (c *CredFile) checkRoleProfile(pfi *ProfileEntryInput) error {
+	name := pfi.ProfileEntryName
+	switch {
+	case pfi.AssumeRoleARN == "":
+		return fmt.Errorf("%w: %s: AssumeRoleARN is required", ErrInvalidRoleProfile, name)
+	case pfi.SourceProfile != "" && pfi.CredentialSource != "":
+		return fmt.Errorf("%w: %s: SourceProfile and CredentialSource can't both be set", ErrInvalidRoleProfile, name)
+	case pfi.SourceProfile == "" && credentialSource(pfi) == "":
+		return fmt.Errorf("%w: %s: one of SourceProfile, CredentialSource or InstanceRoleARN is required", ErrInvalidRoleProfile, name)
+	case pfi.SourceProfile == "" && !validCredentialSource(credentialSource(pfi)):
+		return fmt.Errorf("%w: %s: CredentialSource must be one of %s", ErrInvalidRoleProfile, name, strings.Join(credentialSources, ", "))
+	case pfi.DurationSeconds != 0 && (pfi.DurationSeconds < minRoleDuration || pfi.DurationSeconds > maxRoleDuration):
+		return fmt.Errorf("%w: %s: DurationSeconds must be between %d and %d", ErrInvalidRoleProfile, name, minRoleDuration, maxRoleDuration)
+	}
+	if pfi.SourceProfile == "" {
+		return nil
+	}
+	source := strings.Trim(pfi.SourceProfile, "[]")
+	if source == cleanProfileName(name) {
+		return fmt.Errorf("%w: %s: can't be its own source profile", ErrInvalidRoleProfile, name)
+	}
+	if c.hasProfile(source) {
+		return nil
+	}
+	if c.config != nil && c.config.file.hasProfile(source) {
+		return nil
+	}
+	if c.creds != nil && c.creds.hasProfile(source) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrSourceProfileNotFound, source)
+}
+
+// hasProfile reports whether name is in the file or
+// queued to be written by AssertEntries
+func (c *CredFile) hasProfile(name string) bool {
+	for _, e := range c.ents {
+		if !e.absent && sectionName(e.name) == name {
+			return true
+		}
+	}
+	_, err := c.GetProfile(name)
+	return err == nil
+}
@@ -0,0 +1,163 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoleProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	sess := assertProfiles(t, filename,
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "base"},
+	)
+	pfi := ProfileEntryInput{
+		ProfileEntryName: "admin",
+		RoleProfile:      true,
+		AssumeRoleARN:    "arn:aws:iam::123456789012:role/admin",
+		SourceProfile:    "base",
+		MFASerial:        "arn:aws:iam::123456789012:mfa/me",
+		ExternalID:       "xyz",
+		RoleSessionName:  "me",
+		DurationSeconds:  3600,
+		Region:           "us-west-2",
+	}
+	err = sess.NewEntry(&pfi)
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	want := `[admin]
+region = us-west-2
+role_arn = arn:aws:iam::123456789012:role/admin
+source_profile = base
+mfa_serial = arn:aws:iam::123456789012:mfa/me
+external_id = xyz
+role_session_name = me
+duration_seconds = 3600
+`
+	data, _ := ioutil.ReadFile(filename)
+	if !strings.HasSuffix(string(data), want) {
+		t.Errorf("Unexpected role profile. Have:\n%s\nWant suffix:\n%s", data, want)
+	}
+
+	cases := []struct {
+		pfi ProfileEntryInput
+		err error
+	}{
+		{ProfileEntryInput{SourceProfile: "base"}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn"}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn", SourceProfile: "base", CredentialSource: "Environment"}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn", SourceProfile: "base", DurationSeconds: 60}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn", SourceProfile: "missing"}, ErrSourceProfileNotFound},
+		{ProfileEntryInput{AssumeRoleARN: "arn", SourceProfile: "chained"}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn", SourceProfile: "admin"}, nil},
+		{ProfileEntryInput{AssumeRoleARN: "arn", CredentialSource: "Environment"}, nil},
+		{ProfileEntryInput{AssumeRoleARN: "arn", CredentialSource: "EcsContainer"}, nil},
+		{ProfileEntryInput{AssumeRoleARN: "arn", CredentialSource: "Ec2InstanceMetadata"}, nil},
+		{ProfileEntryInput{AssumeRoleARN: "arn", CredentialSource: "Ec2InstanceMetaData"}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn", CredentialSource: "environment"}, ErrInvalidRoleProfile},
+		{ProfileEntryInput{AssumeRoleARN: "arn", InstanceRoleARN: "arn:instance"}, nil},
+	}
+	for i, tc := range cases {
+		tc.pfi.ProfileEntryName = "chained"
+		tc.pfi.RoleProfile = true
+		err = sess.Begin().Upsert(&tc.pfi)
+		if !errors.Is(err, tc.err) || (err == nil) != (tc.err == nil) {
+			t.Errorf("Unexpected error for case %d. Have: %v, Want: %v", i, err, tc.err)
+		}
+	}
+	tx := sess.Begin()
+	tx.Upsert(&ProfileEntryInput{ProfileEntryName: "ec2", RoleProfile: true, AssumeRoleARN: "arn", InstanceRoleARN: "arn:instance"})
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	p, err := sess.GetProfile("ec2")
+	if err != nil || p.Values["credential_source"] != instanceCredentialSource {
+		t.Errorf("Expected credential_source from InstanceRoleARN, got: %v %v", p, err)
+	}
+}
+
+func TestRoleProfileWithConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess := assertProfiles(t, filepath.Join(dir, "credentials"),
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "admin"},
+	)
+	cf, err := NewConfigFileSession(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "config"), []byte("[profile sso]\nsso_session = corp\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.file.reload()
+	sess.SetConfigFile(cf)
+	sess.ents = nil
+	// the source only exists in the config file
+	err = sess.NewEntry(&ProfileEntryInput{ProfileEntryName: "admin", RoleProfile: true, AssumeRoleARN: "arn", SourceProfile: "sso"})
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = sess.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	// old static keys would take precedence so they have to go
+	if _, err = sess.GetProfile("admin"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected admin to be removed from credentials file, got: %v", err)
+	}
+	p, err := cf.GetProfile("admin")
+	if err != nil || p.Values["role_arn"] != "arn" || p.Values["source_profile"] != "sso" {
+		t.Errorf("Unexpected config profile: %v %v", p, err)
+	}
+}
+
+func TestConfigFileRoleSourceInCredFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sess := assertProfiles(t, filepath.Join(dir, "credentials"),
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "base"},
+	)
+	cf, err := NewConfigFileSession(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Error making config file session: %s", err)
+	}
+	role := &ProfileEntryInput{ProfileEntryName: "admin", RoleProfile: true, AssumeRoleARN: "arn", SourceProfile: "base"}
+	err = cf.NewEntry(role)
+	if !errors.Is(err, ErrSourceProfileNotFound) {
+		t.Errorf("Expected ErrSourceProfileNotFound without a linked credentials file, got: %v", err)
+	}
+	// the source only exists in the credentials file
+	cf.SetCredFile(sess)
+	err = cf.NewEntry(role)
+	if err != nil {
+		t.Fatalf("Error adding entry: %s", err)
+	}
+	err = cf.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	p, err := cf.GetProfile("admin")
+	if err != nil || p.Values["source_profile"] != "base" {
+		t.Errorf("Unexpected config profile: %v %v", p, err)
+	}
+}
@@ -0,0 +1,101 @@
+package acfmgr
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// ErrConcurrentModification is returned when the credentials file
+// changed on disk since it was loaded and the CredFile is using
+// the FailOnModification policy.
+var ErrConcurrentModification = errors.New("credentials file was modified by another process")
+
+// maxReapply is how many times we'll reload and reapply queued
+// entries before giving up on a file that keeps changing
+const maxReapply = 3
+
+// ModificationPolicy decides what a CredFile does when the file
+// on disk changed since it was last read, e.g., because the AWS
+// CLI or an editor wrote to it.
+type ModificationPolicy int
+
+const (
+	// ReloadOnModification re-reads the file and re-applies the
+	// queued entries on top of the new contents. This is the default.
+	ReloadOnModification ModificationPolicy = iota
+	// FailOnModification returns ErrConcurrentModification
+	// without writing anything.
+	FailOnModification
+)
+
+// SetModificationPolicy changes how the CredFile reacts
+// to the file changing underneath it.
+func (c *CredFile) SetModificationPolicy(p ModificationPolicy) {
+	c.policy = p
+}
+
+// fileSnapshot records enough about the file to tell if someone
+// else has written to it. The mtime isn't kept since coarse
+// timestamps can't be trusted to skip the hash.
+type fileSnapshot struct {
+	size int64
+	sum  [sha256.Size]byte
+}
+
+func newSnapshot(info os.FileInfo, data []byte) fileSnapshot {
+	return fileSnapshot{
+		size: info.Size(),
+		sum:  sha256.Sum256(data),
+	}
+}
+
+// modifiedOnDisk compares the file on disk to the last snapshot.
+// Only the size and hash decide, a touched file with the same
+// contents isn't considered modified.
+func (c *CredFile) modifiedOnDisk() (bool, error) {
+	info, err := os.Stat(c.filename)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != c.snap.size {
+		return true, nil
+	}
+	data, err := ioutil.ReadFile(c.filename)
+	if err != nil {
+		return false, err
+	}
+	return sha256.Sum256(data) != c.snap.sum, nil
+}
+
+// applyFresh runs fn against the latest contents of the file. If
+// the file changed since it was loaded, or changes while fn is
+// running, the policy decides whether to reload and rerun fn or
+// bail out with ErrConcurrentModification.
+func (c *CredFile) applyFresh(fn func() error) (err error) {
+	for attempt := 0; ; attempt++ {
+		changed, err := c.modifiedOnDisk()
+		if err != nil {
+			return err
+		}
+		if changed {
+			if c.policy == FailOnModification || attempt >= maxReapply {
+				return ErrConcurrentModification
+			}
+			err = c.reload()
+			if err != nil {
+				return err
+			}
+		}
+		err = fn()
+		// fn can also fail on another file, e.g. an attached ConfigFile,
+		// so don't retry forever just because ours hasn't changed
+		if !errors.Is(err, ErrConcurrentModification) || attempt >= maxReapply {
+			return err
+		}
+	}
+}
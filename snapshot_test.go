@@ -0,0 +1,70 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// externalEdit appends a hand written profile the way an
+// editor or the AWS CLI would, bypassing acfmgr entirely
+func externalEdit(t *testing.T, filename string) {
+	t.Helper()
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Error opening file: %s", err)
+	}
+	defer f.Close()
+	_, err = f.WriteString("[external]\naws_access_key_id = foo\n")
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+}
+
+func TestConcurrentModification(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	cases := []struct {
+		Policy      ModificationPolicy
+		ExpectedErr error
+	}{
+		{Policy: ReloadOnModification},
+		{Policy: FailOnModification, ExpectedErr: ErrConcurrentModification},
+	}
+	for i, c := range cases {
+		filename := filepath.Join(dir, "credentials"+string(rune('a'+i)))
+		err = writeBaseFile(filename)
+		if err != nil {
+			t.Fatalf("Error making basefile: %s", err)
+		}
+		sess, err := NewCredFileSession(filename)
+		if err != nil {
+			t.Fatalf("Error making credfile session: %s", err)
+		}
+		sess.SetModificationPolicy(c.Policy)
+		sess.SetLockOptions(LockOptions{Disabled: true})
+		err = sess.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "acfmgrtest"})
+		if err != nil {
+			t.Fatalf("Error adding entry: %s", err)
+		}
+		externalEdit(t, filename)
+		err = sess.AssertEntries()
+		if !errors.Is(err, c.ExpectedErr) {
+			t.Errorf("Unexpected error for policy %d. Have: %v, Want: %v", c.Policy, err, c.ExpectedErr)
+		}
+		got, _ := ioutil.ReadFile(filename)
+		if !strings.Contains(string(got), "[external]") {
+			t.Errorf("External change was lost for policy %d: %s", c.Policy, got)
+		}
+		wantOurs := c.ExpectedErr == nil
+		if strings.Contains(string(got), "[acfmgrtest]") != wantOurs {
+			t.Errorf("Unexpected presence of queued entry for policy %d: %s", c.Policy, got)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package acfmgr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrTxDone is returned when a Tx is used after
+// Commit or Rollback.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// ErrInvalidPatch is returned when a Patch has a key or
+// value that can't be written as a single ini line.
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ErrProfileExists is returned when renaming a profile
+// to a name that's already in the file.
+var ErrProfileExists = errors.New("profile already exists")
+
+type txOpKind int
+
+const (
+	opUpsert txOpKind = iota
+	opDelete
+	opRename
+	opPatch
+	opRefresh
+	opMerge
+)
+
+// txOp is a single queued change to the file
+type txOp struct {
+	kind    txOpKind
+	entry   *credEntry        // for upsert, refresh and merge
+	name    string            // profile name for delete, rename, patch and merge
+	newName string            // new profile name for rename
+	values  map[string]string // for patch
+}
+
+// Tx is a set of changes to a CredFile that are applied together
+// in a single write with Commit or thrown away with Rollback. Build
+// one with CredFile.Begin.
+type Tx struct {
+	c         *CredFile
+	ops       []txOp
+	configOps []txOp // for the attached ConfigFile, see SetConfigFile
+	err       error
+	done      bool
+}
+
+// Begin starts a new transaction against the CredFile.
+func (c *CredFile) Begin() *Tx {
+	return &Tx{c: c}
+}
+
+// Upsert queues the profile entry to replace any existing
+// sections with the same name or be added if there are none. With
+// a ConfigFile attached the settings go to it like they do with
+// NewEntry.
+func (t *Tx) Upsert(pfi *ProfileEntryInput) (err error) {
+	name, err := t.c.checkName(pfi.ProfileEntryName)
+	if err != nil {
+		if t.err == nil {
+			t.err = err
+		}
+		return err
+	}
+	if pfi.RoleProfile {
+		err = t.c.checkRoleProfile(pfi)
+	}
+	if pfi.CredentialProcess {
+		err = ErrProcessInTx
+	}
+	if err != nil {
+		if t.err == nil {
+			t.err = err
+		}
+		return err
+	}
+	if t.c.config != nil {
+		if cfg := buildConfigEntry(pfi, name); len(cfg.contents) > 0 {
+			t.configOps = append(t.configOps, txOp{kind: opMerge, name: name, entry: cfg})
+		}
+		if pfi.RoleProfile {
+			// keys left here would take precedence over the role
+			t.ops = append(t.ops, txOp{kind: opDelete, name: name})
+			return err
+		}
+		secrets := *pfi
+		secrets.Region = ""
+		secrets.OutputFormat = ""
+		pfi = &secrets
+	}
+	e, err := buildEntry(pfi, name)
+	if err != nil {
+		if t.err == nil {
+			t.err = err
+		}
+		return err
+	}
+	t.ops = append(t.ops, txOp{kind: opUpsert, entry: e})
+	return err
+}
+
+// Delete queues removal of every section with the given name,
+// from the attached ConfigFile too if there is one.
+func (t *Tx) Delete(name string) {
+	name = cleanProfileName(name)
+	t.ops = append(t.ops, txOp{kind: opDelete, name: name})
+	if t.c.config != nil {
+		t.configOps = append(t.configOps, txOp{kind: opDelete, name: name})
+	}
+}
+
+// Rename queues renaming the profile from one name to another.
+// Commit fails if from doesn't exist, to already does or to
+// isn't allowed by the NamePolicy.
+func (t *Tx) Rename(from, to string) {
+	newName, err := t.c.checkName(to)
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	t.ops = append(t.ops, txOp{kind: opRename, name: cleanProfileName(from), newName: newName})
+}
+
+// Patch queues setting keys on an existing profile without touching
+// anything else in the section. Keys that don't exist yet are added
+// to the end of the section and a blank value removes the key.
+// Commit fails if the profile doesn't exist or any key or value
+// can't be written as a single line.
+func (t *Tx) Patch(name string, values map[string]string) {
+	err := checkPatch(name, values)
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	t.ops = append(t.ops, txOp{kind: opPatch, name: cleanProfileName(name), values: values})
+}
+
+// checkPatch makes sure every key is a plain ini key and
+// no value would spill onto another line
+func checkPatch(name string, values map[string]string) error {
+	for k, v := range values {
+		if k == "" || strings.ContainsAny(k, "=[]#;") || strings.IndexFunc(k, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("%w: %s: bad key %q", ErrInvalidPatch, name, k)
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("%w: %s: value for %s has a line break", ErrInvalidPatch, name, k)
+		}
+	}
+	return nil
+}
+
+// Commit applies all of the queued changes in one write. If any
+// of them fail validation nothing is written. With a ConfigFile
+// attached both files are written together like AssertEntries.
+func (t *Tx) Commit() (err error) {
+	if t.done {
+		return ErrTxDone
+	}
+	if t.err != nil {
+		return t.err
+	}
+	err = t.c.withConfig(
+		func() error { return t.c.applyOps(t.ops) },
+		func() error { return t.c.config.file.applyOps(t.configOps) },
+	)
+	if err != nil {
+		return err
+	}
+	t.done = true
+	t.ops = nil
+	t.configOps = nil
+	return err
+}
+
+// Rollback drops all of the queued changes.
+func (t *Tx) Rollback() {
+	t.done = true
+	t.ops = nil
+	t.configOps = nil
+}
+
+// applyOps applies the ops in order to one parsed copy of the buffer
+// and writes the result once. Nothing is written if any op fails.
+func (c *CredFile) applyOps(ops []txOp) (err error) {
+	buf, err := c.renderOps(ops)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(buf.Bytes(), c.currBuff.Bytes()) {
+		// nothing to do so don't touch the file
+		return err
+	}
+	return c.writeBufferToFile(buf)
+}
+
+// renderOps returns what the buffer would look like
+// after applying the ops without writing anything
+func (c *CredFile) renderOps(ops []txOp) (buf *bytes.Buffer, err error) {
+	doc := c.document()
+	var run []txOp
+	for _, op := range ops {
+		if op.kind == opUpsert || op.kind == opDelete {
+			run = append(run, op)
+			continue
+		}
+		applyRun(doc, run)
+		run = nil
+		err = op.apply(doc)
+		if err != nil {
+			return buf, err
+		}
+	}
+	applyRun(doc, run)
+	return doc.bytes(), err
+}
+
+// document parses the current buffer
+func (c *CredFile) document() *document {
+	doc := parseDocument(c.currBuff.Bytes())
+	if c.prefix != "" {
+		doc.usePrefix(c.prefix)
+	}
+	return doc
+}
+
+// applyRun applies a run of upserts and deletes in a single pass
+// over the sections. The result is the same as applying them one at
+// a time: the last op for a name decides whether it ends up in the
+// file and upserted entries are appended in the order of their last
+// upsert.
+func applyRun(doc *document, run []txOp) {
+	if len(run) == 0 {
+		return
+	}
+	last := make(map[string]int)
+	for i, op := range run {
+		last[op.profile()] = i
+	}
+	kept := doc.sections[:0:0]
+	for _, s := range doc.sections {
+		if _, ok := last[s.name()]; !ok || s.header == nil {
+			kept = append(kept, s)
+		}
+	}
+	doc.sections = kept
+	for i, op := range run {
+		if op.kind == opUpsert && last[op.profile()] == i {
+			doc.terminate()
+			doc.sections = append(doc.sections, doc.newSection(op.profile(), op.entry.contents))
+		}
+	}
+}
+
+// profile returns the name of the profile the op works on
+func (op txOp) profile() string {
+	if op.kind == opUpsert {
+		return sectionName(op.entry.name)
+	}
+	return op.name
+}
+
+func (op txOp) apply(doc *document) error {
+	switch op.kind {
+	case opUpsert, opDelete:
+		applyRun(doc, []txOp{op})
+		return nil
+	case opRename:
+		if findSections(doc, op.newName) != nil {
+			return fmt.Errorf("rename %s to %s: %w", op.name, op.newName, ErrProfileExists)
+		}
+		found := findSections(doc, op.name)
+		if found == nil {
+			return fmt.Errorf("rename %s: %w", op.name, ErrProfileNotFound)
+		}
+		for _, s := range found {
+			// keep the original line ending
+			eol := strings.TrimPrefix(s.header.raw, s.header.text())
+			header := doc.header(op.newName, eol, s.header.line)
+			s.header = &header
+		}
+		return nil
+	case opRefresh:
+		found := findSections(doc, op.name)
+		if found == nil {
+			return fmt.Errorf("refresh %s: %w", op.name, ErrProfileNotFound)
+		}
+		// swap the body but leave the section where it is
+		fresh := doc.newSection(op.name, op.entry.contents)
+		for _, s := range found {
+			s.body = fresh.body
+		}
+		return nil
+	case opPatch:
+		found := findSections(doc, op.name)
+		if found == nil {
+			return fmt.Errorf("patch %s: %w", op.name, ErrProfileNotFound)
+		}
+		for _, s := range found {
+			s.body = patchBody(doc, s.body, op.values)
+		}
+		return nil
+	case opMerge:
+		// like a patch but adds the profile when it's missing
+		found := findSections(doc, op.name)
+		if found == nil {
+			doc.terminate()
+			doc.sections = append(doc.sections, doc.newSection(op.name, op.entry.contents))
+		}
+		for _, s := range found {
+			s.body = patchBody(doc, s.body, op.entry.values)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown transaction op %d", op.kind)
+}
+
+// sectionName strips the brackets from a section header
+// like the ones stored in credEntry.name
+func sectionName(header string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(header, "["), "]")
+}
+
+// findSections returns every section with the given name
+func findSections(doc *document, name string) (found []*section) {
+	for _, s := range doc.sections {
+		if s.header != nil && s.name() == name {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// patchBody sets or removes the keys in a section body. Lines that
+// aren't patched are kept exactly as they were.
+func patchBody(doc *document, body []token, values map[string]string) []token {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	seen := make(map[string]bool)
+	var out []token
+	dropping := false // skipping continuation lines of a replaced key
+	for _, t := range body {
+		if dropping && t.kind == tokContinuation {
+			continue
+		}
+		dropping = false
+		if t.kind == tokKeyValue {
+			if val, ok := values[t.key]; ok {
+				seen[t.key] = true
+				dropping = true
+				if val != "" {
+					out = append(out, doc.newLine(fmt.Sprintf("%s = %s", t.key, val)))
+				}
+				continue
+			}
+		}
+		out = append(out, t)
+	}
+	// new keys go before any trailing blank lines
+	end := len(out)
+	for end > 0 && out[end-1].kind == tokBlank {
+		end--
+	}
+	if end > 0 && !strings.HasSuffix(out[end-1].raw, "\n") {
+		out[end-1].raw += doc.eol
+	}
+	var added []token
+	for _, k := range keys {
+		if !seen[k] && values[k] != "" {
+			added = append(added, doc.newLine(fmt.Sprintf("%s = %s", k, values[k])))
+		}
+	}
+	tail := append([]token{}, out[end:]...)
+	return append(append(out[:end], added...), tail...)
+}
@@ -0,0 +1,142 @@
+package acfmgr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTxCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	sess := assertProfiles(t, filename,
+		ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "old-alias", Region: "us-east-1"},
+	)
+	tx := sess.Begin()
+	err = tx.Upsert(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "rotated"})
+	if err != nil {
+		t.Fatalf("Error queueing upsert: %s", err)
+	}
+	tx.Delete("newentry")
+	tx.Rename("old-alias", "new-alias")
+	tx.Patch("new-alias", map[string]string{"region": "us-west-2", "output": "json"})
+	tx.Patch("testing", map[string]string{"foo": "bar"})
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	var names []string
+	for _, p := range sess.ListProfiles() {
+		names = append(names, p.Name)
+	}
+	want := "testing,new-alias,rotated"
+	if strings.Join(names, ",") != want {
+		t.Errorf("Unexpected profiles. Have: %v, Want: %s", names, want)
+	}
+	p, _ := sess.GetProfile("new-alias")
+	if p.Values["region"] != "us-west-2" || p.Values["output"] != "json" {
+		t.Errorf("Patch not applied: %v", p.Values)
+	}
+	if !p.Managed {
+		t.Errorf("Rename lost the managed header")
+	}
+	if tx.Commit() != ErrTxDone {
+		t.Errorf("Expected ErrTxDone on second commit")
+	}
+}
+
+func TestTxValidationWritesNothing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = writeBaseFile(filename)
+	if err != nil {
+		t.Fatalf("Error making basefile: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	cases := []struct {
+		Queue       func(tx *Tx)
+		ExpectedErr error
+	}{
+		{
+			Queue:       func(tx *Tx) { tx.Rename("missing", "whatever") },
+			ExpectedErr: ErrProfileNotFound,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Rename("testing", "newentry") },
+			ExpectedErr: ErrProfileExists,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("missing", map[string]string{"a": "b"}) },
+			ExpectedErr: ErrProfileNotFound,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"region": "us-east-1\n[evil]"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"region": "us-east-1\r"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"": "b"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"a = b": "c"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"[a]": "c"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"#a": "c"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"a;": "c"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+		{
+			Queue:       func(tx *Tx) { tx.Patch("testing", map[string]string{"a\tb": "c"}) },
+			ExpectedErr: ErrInvalidPatch,
+		},
+	}
+	for _, c := range cases {
+		tx := sess.Begin()
+		tx.Delete("testing")
+		c.Queue(tx)
+		err = tx.Commit()
+		if !errors.Is(err, c.ExpectedErr) {
+			t.Errorf("Unexpected error. Have: %v, Want: %v", err, c.ExpectedErr)
+		}
+		got, _ := ioutil.ReadFile(filename)
+		if string(got) != baseCredFile {
+			t.Errorf("File was modified despite failed validation: %s", got)
+		}
+	}
+	tx := sess.Begin()
+	tx.Delete("testing")
+	tx.Rollback()
+	if tx.Commit() != ErrTxDone {
+		t.Errorf("Expected ErrTxDone after rollback")
+	}
+	got, _ := ioutil.ReadFile(filename)
+	if string(got) != baseCredFile {
+		t.Errorf("File was modified after rollback: %s", got)
+	}
+}
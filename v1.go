@@ -0,0 +1,77 @@
+package acfmgr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// CredentialsFromV1Value converts an aws-sdk-go v1 credentials.Value
+// for use as ProfileEntryInput.Credential. A Value doesn't carry an
+// expiry so the result can't expire, use CredentialsFromV1 to keep it.
+func CredentialsFromV1Value(v credentials.Value) (*aws.Credentials, error) {
+	creds := &aws.Credentials{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: v.SecretAccessKey,
+		SessionToken:    v.SessionToken,
+		Source:          v.ProviderName,
+	}
+	if !creds.HasKeys() {
+		return nil, fmt.Errorf("%w: credentials.Value from %q", ErrMissingKeys, v.ProviderName)
+	}
+	return creds, nil
+}
+
+// CredentialsFromV1 retrieves an aws-sdk-go v1 *credentials.Credentials
+// and converts the result for use as ProfileEntryInput.Credential. The
+// expiry is taken from ExpiresAt when the provider reports one.
+func CredentialsFromV1(c *credentials.Credentials) (*aws.Credentials, error) {
+	v, err := c.Get()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := CredentialsFromV1Value(v)
+	if err != nil {
+		return nil, err
+	}
+	// providers that don't expire return an error here
+	// rather than a zero time
+	if t, err := c.ExpiresAt(); err == nil && !t.IsZero() {
+		setV1Expiry(creds, t)
+	}
+	return creds, nil
+}
+
+// CredentialsFromSTS converts the aws-sdk-go v1 sts.Credentials
+// returned by AssumeRole, GetSessionToken and friends for use as
+// ProfileEntryInput.Credential. A nil Expiration means the
+// credentials can't expire.
+func CredentialsFromSTS(c *sts.Credentials) (*aws.Credentials, error) {
+	if c == nil {
+		return nil, fmt.Errorf("%w: nil sts.Credentials", ErrMissingKeys)
+	}
+	creds := &aws.Credentials{
+		AccessKeyID:     awsv1.StringValue(c.AccessKeyId),
+		SecretAccessKey: awsv1.StringValue(c.SecretAccessKey),
+		SessionToken:    awsv1.StringValue(c.SessionToken),
+		Source:          sts.ServiceName,
+	}
+	if !creds.HasKeys() {
+		return nil, fmt.Errorf("%w: sts.Credentials", ErrMissingKeys)
+	}
+	if c.Expiration != nil && !c.Expiration.IsZero() {
+		setV1Expiry(creds, *c.Expiration)
+	}
+	return creds, nil
+}
+
+// setV1Expiry sets the expiry in UTC so it's written
+// the same way no matter the local zone of the service
+func setV1Expiry(creds *aws.Credentials, t time.Time) {
+	creds.CanExpire = true
+	creds.Expires = t.UTC()
+}
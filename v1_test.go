@@ -0,0 +1,77 @@
+package acfmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// expiringV1Provider is a v1 provider that reports an expiry
+type expiringV1Provider struct {
+	credentials.Expiry
+	value credentials.Value
+}
+
+func (p *expiringV1Provider) Retrieve() (credentials.Value, error) {
+	return p.value, nil
+}
+
+func TestCredentialsFromV1(t *testing.T) {
+	fake := getFakeCreds()
+	static := credentials.NewStaticCredentials(fake.AccessKeyID, fake.SecretAccessKey, fake.SessionToken)
+	creds, err := CredentialsFromV1(static)
+	if err != nil {
+		t.Fatalf("Error converting static credentials: %s", err)
+	}
+	if creds.CanExpire || creds.AccessKeyID != fake.AccessKeyID || creds.Source != credentials.StaticProviderName {
+		t.Errorf("Unexpected static credentials: %+v", creds)
+	}
+
+	p := &expiringV1Provider{value: credentials.Value{AccessKeyID: "A", SecretAccessKey: "B", ProviderName: "fake"}}
+	local := time.FixedZone("EST", -5*60*60)
+	p.SetExpiration(fake.Expires.In(local), 0)
+	creds, err = CredentialsFromV1(credentials.NewCredentials(p))
+	if err != nil {
+		t.Fatalf("Error converting expiring credentials: %s", err)
+	}
+	if !creds.CanExpire || !creds.Expires.Equal(fake.Expires) || creds.Expires.Location() != time.UTC {
+		t.Errorf("Unexpected expiry. Have: %v %s, Want: %s", creds.CanExpire, creds.Expires, fake.Expires)
+	}
+
+	_, err = CredentialsFromV1Value(credentials.Value{AccessKeyID: "A"})
+	if !errors.Is(err, ErrMissingKeys) {
+		t.Errorf("Expected ErrMissingKeys, got: %v", err)
+	}
+}
+
+func TestCredentialsFromSTS(t *testing.T) {
+	fake := getFakeCreds()
+	c := &sts.Credentials{
+		AccessKeyId:     awsv1.String(fake.AccessKeyID),
+		SecretAccessKey: awsv1.String(fake.SecretAccessKey),
+		SessionToken:    awsv1.String(fake.SessionToken),
+		Expiration:      awsv1.Time(fake.Expires),
+	}
+	creds, err := CredentialsFromSTS(c)
+	if err != nil {
+		t.Fatalf("Error converting sts credentials: %s", err)
+	}
+	if !creds.CanExpire || !creds.Expires.Equal(fake.Expires) || creds.SessionToken != fake.SessionToken {
+		t.Errorf("Unexpected credentials: %+v", creds)
+	}
+	c.Expiration = nil
+	creds, err = CredentialsFromSTS(c)
+	if err != nil || creds.CanExpire {
+		t.Errorf("Expected credentials that can't expire, got: %+v %v", creds, err)
+	}
+	if _, err = CredentialsFromSTS(&sts.Credentials{}); !errors.Is(err, ErrMissingKeys) {
+		t.Errorf("Expected ErrMissingKeys, got: %v", err)
+	}
+	if _, err = CredentialsFromSTS(nil); !errors.Is(err, ErrMissingKeys) {
+		t.Errorf("Expected ErrMissingKeys for nil, got: %v", err)
+	}
+}
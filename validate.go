@@ -0,0 +1,145 @@
+package acfmgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how bad a Finding is.
+type Severity string
+
+// severities used by Validate
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// FindingKind identifies the problem a Finding describes.
+type FindingKind string
+
+// kinds of findings returned by Validate
+const (
+	FindingDuplicateSection FindingKind = "duplicate-section"
+	FindingOrphanKey        FindingKind = "key-outside-section"
+	FindingMalformedHeader  FindingKind = "malformed-header"
+	FindingInvalidLine      FindingKind = "invalid-line"
+	FindingMissingKeys      FindingKind = "managed-missing-keys"
+	FindingEmptySection     FindingKind = "empty-section"
+)
+
+// Finding is a single problem found by Validate.
+type Finding struct {
+	Kind     FindingKind
+	Severity Severity
+	Line     int    // 1 based line number in the file
+	Profile  string // blank when the problem isn't inside a profile
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Profile == "" {
+		return fmt.Sprintf("line %d: %s: %s", f.Line, f.Severity, f.Message)
+	}
+	return fmt.Sprintf("line %d: %s: [%s] %s", f.Line, f.Severity, f.Profile, f.Message)
+}
+
+// Validate lints the contents loaded in the session and returns
+// any findings in line order. Duplicate sections are worth fixing
+// since AssertEntries and DeleteEntries act on all of them.
+func (c *CredFile) Validate() (findings []Finding) {
+	doc := c.document()
+	firstSeen := make(map[string]int)
+	for _, s := range doc.sections {
+		name := s.name()
+		if s.header != nil {
+			if first, ok := firstSeen[name]; ok {
+				findings = append(findings, Finding{
+					Kind:     FindingDuplicateSection,
+					Severity: SeverityWarning,
+					Line:     s.header.line,
+					Profile:  name,
+					Message:  fmt.Sprintf("duplicate of the section on line %d", first),
+				})
+			} else {
+				firstSeen[name] = s.header.line
+			}
+			if name == "" {
+				findings = append(findings, Finding{
+					Kind:     FindingMalformedHeader,
+					Severity: SeverityError,
+					Line:     s.header.line,
+					Message:  "section header has no name",
+				})
+			}
+		}
+		empty := true
+		managed := false
+		hasKeys := make(map[string]bool)
+		for _, t := range s.body {
+			switch t.kind {
+			case tokKeyValue, tokContinuation:
+				empty = false
+				hasKeys[t.key] = true
+				if s.header == nil {
+					findings = append(findings, Finding{
+						Kind:     FindingOrphanKey,
+						Severity: SeverityError,
+						Line:     t.line,
+						Message:  fmt.Sprintf("'%s' is not inside any section", strings.TrimSpace(t.text())),
+					})
+				}
+			case tokComment:
+				if strings.TrimSpace(t.text()) == managedMarker {
+					managed = true
+				}
+			case tokInvalid:
+				f := Finding{
+					Kind:     FindingInvalidLine,
+					Severity: SeverityError,
+					Line:     t.line,
+					Profile:  name,
+					Message:  fmt.Sprintf("can't parse '%s'", strings.TrimSpace(t.text())),
+				}
+				if strings.HasPrefix(strings.TrimSpace(t.text()), "[") {
+					f.Kind = FindingMalformedHeader
+					f.Message = fmt.Sprintf("malformed section header '%s'", strings.TrimSpace(t.text()))
+				}
+				findings = append(findings, f)
+			}
+		}
+		if s.header == nil {
+			continue
+		}
+		if empty {
+			findings = append(findings, Finding{
+				Kind:     FindingEmptySection,
+				Severity: SeverityWarning,
+				Line:     s.header.line,
+				Profile:  name,
+				Message:  "section has no keys",
+			})
+		}
+		if managed {
+			var missing []string
+			for _, key := range []string{"aws_access_key_id", "aws_secret_access_key"} {
+				if !hasKeys[key] {
+					missing = append(missing, key)
+				}
+			}
+			if len(missing) > 0 {
+				findings = append(findings, Finding{
+					Kind:     FindingMissingKeys,
+					Severity: SeverityError,
+					Line:     s.header.line,
+					Profile:  name,
+					Message:  fmt.Sprintf("managed section is missing %s", strings.Join(missing, ", ")),
+				})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
@@ -0,0 +1,72 @@
+package acfmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const lintCredFile string = `stray = value
+[dupe]
+a = b
+
+[empty]
+# just a comment
+
+[dupe]
+c = d
+[broken
+[managed]
+# ACFMGR MANAGED SECTION
+aws_access_key_id = foo
+not a key value
+`
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acfmgr")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(filename, []byte(lintCredFile), 0600)
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	sess, err := NewCredFileSession(filename)
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	want := []Finding{
+		{Kind: FindingOrphanKey, Severity: SeverityError, Line: 1},
+		{Kind: FindingEmptySection, Severity: SeverityWarning, Line: 5, Profile: "empty"},
+		{Kind: FindingDuplicateSection, Severity: SeverityWarning, Line: 8, Profile: "dupe"},
+		{Kind: FindingMalformedHeader, Severity: SeverityError, Line: 10, Profile: "dupe"},
+		{Kind: FindingMissingKeys, Severity: SeverityError, Line: 11, Profile: "managed"},
+		{Kind: FindingInvalidLine, Severity: SeverityError, Line: 14, Profile: "managed"},
+	}
+	have := sess.Validate()
+	if len(have) != len(want) {
+		t.Fatalf("Unexpected number of findings. Have: %v", have)
+	}
+	for i, w := range want {
+		h := have[i]
+		if h.Kind != w.Kind || h.Severity != w.Severity || h.Line != w.Line || h.Profile != w.Profile {
+			t.Errorf("Unexpected finding. Have: %+v, Want: %+v", h, w)
+		}
+	}
+
+	clean, err := NewCredFileSession(filepath.Join(dir, "clean"))
+	if err != nil {
+		t.Fatalf("Error making credfile session: %s", err)
+	}
+	clean.NewEntry(&ProfileEntryInput{Credential: getFakeCreds(), ProfileEntryName: "acfmgrtest", Description: "[legacy]"})
+	err = clean.AssertEntries()
+	if err != nil {
+		t.Fatalf("Error asserting entries: %s", err)
+	}
+	if findings := clean.Validate(); len(findings) != 0 {
+		t.Errorf("Expected no findings for a clean file. Have: %v", findings)
+	}
+}